@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+)
+
+// analyze reports how well the configured palette covers the colors of
+// the source image, without rendering a mosaic.
+func analyze(args []string) {
+	var (
+		fs        = flag.NewFlagSet("analyze", flag.ExitOnError)
+		tiles     tileFlags
+		outp      string
+		heatp     string
+		threshold float64
+		n         int
+	)
+	tiles.register(fs)
+	fs.StringVar(&outp, "o", "", "Destination path to write the JSON report to (otherwise STDOUT)")
+	fs.StringVar(&heatp, "heatmap", "", "Destination path to write a PNG heatmap of match quality to")
+	fs.Float64Var(&threshold, "e", 10, "Max ΔE for a cell to count as covered")
+	fs.IntVar(&n, "n", 10, "Number of worst cells and suggested colors to report")
+	fs.Parse(args)
+
+	im := load(fs.Arg(0))
+
+	p, source := tiles.generator(im)
+	decoder := mosaic.NewConverter(im,
+		source,
+		mosaic.WithWidth(tiles.width),
+		mosaic.WithHeight(tiles.height),
+		mosaic.WithSize(tiles.size),
+		mosaic.WithMeanColor(tiles.mean()),
+		mosaic.WithColorSpace(tiles.colorSpace()),
+		mosaic.WithPaletteGenerator(p))
+	report, err := decoder.Analyze(threshold, n)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, closer := create(outp)
+	defer closer()
+
+	enc, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := out.Write(append(enc, '\n')); err != nil {
+		log.Fatal(err)
+	}
+
+	if heatp != "" {
+		fi, err := os.Create(heatp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer fi.Close()
+		if err := png.Encode(fi, report.Heatmap(im.Bounds())); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
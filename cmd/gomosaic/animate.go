@@ -0,0 +1,72 @@
+package main
+
+import (
+	"image"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+)
+
+// loadAnimation returns the frames found at path, cropped by crop, when
+// it is a directory of frames or an animated GIF, otherwise nil.
+func loadAnimation(path string, delay int, crop func(image.Image) image.Image) *mosaic.Animation {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var anim *mosaic.Animation
+	switch {
+	case info.IsDir():
+		if anim, err = mosaic.LoadFrames(path, delay); err != nil {
+			log.Fatal(err)
+		}
+	case strings.ToLower(filepath.Ext(path)) == ".gif":
+		fi, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer fi.Close()
+		if anim, err = mosaic.DecodeAnimation(fi); err != nil {
+			log.Fatal("Decoding Error: ", err)
+		}
+		if len(anim.Frames) < 2 {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	for i, frame := range anim.Frames {
+		anim.Frames[i] = crop(frame)
+	}
+	return anim
+}
+
+// animate renders every frame of anim, writing an animated GIF when
+// path ends in .gif and otherwise a directory of numbered PNG frames.
+func animate(decoder *mosaic.Converter, anim *mosaic.Animation, path string) {
+	if path == "" {
+		log.Fatal("Animations need a destination (-o), either a .gif or a directory for frames")
+	}
+
+	out, err := decoder.DecodeAnimation(anim)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".gif" {
+		write(path, func(w io.Writer) error {
+			return out.EncodeGIF(w)
+		})
+		return
+	}
+
+	if err := out.WriteFrames(path); err != nil {
+		log.Fatal(err)
+	}
+}
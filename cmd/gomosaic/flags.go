@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+	"github.com/GeorgeMac/gomosaic/remote"
+)
+
+// tileFlags are the flags describing the tile grid and where its tiles
+// come from, shared by every mode of the command.
+type tileFlags struct {
+	width, height, size  int
+	dirp, endpoint, term string
+	colors, quantiser    string
+	space                string
+	weights, priorities  string
+	quantise             int
+	fill                 bool
+}
+
+func (f *tileFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&f.width, "w", 50, "Width in number of tiles")
+	fs.IntVar(&f.height, "h", 50, "Height in number of tiles")
+	fs.IntVar(&f.size, "t", 100, "Tile size in t/t px")
+	fs.StringVar(&f.dirp, "d", "", "Comma separated locations of images or saved palettes to use as tiles")
+	fs.StringVar(&f.endpoint, "r", "", "Remote search endpoint to fetch tiles from (cached in the first -d, with any others used as well)")
+	fs.StringVar(&f.term, "q", "", "Search term for the remote endpoint")
+	fs.BoolVar(&f.fill, "f", false, "Fill gaps in tile coverage with uniform web colors")
+	fs.StringVar(&f.colors, "colors", "", "Named color set (websafe, plan9) or hex, .gpl or .ase file of uniform tile colors")
+	fs.IntVar(&f.quantise, "quantise", 0, "Use N uniform tile colors quantised from the source image")
+	fs.StringVar(&f.quantiser, "quantiser", "median", "Quantisation method (median, kmeans)")
+	fs.StringVar(&f.weights, "weights", "", "Comma separated weights of tile sources, in the order -r, -d, -colors, -quantise")
+	fs.StringVar(&f.priorities, "priorities", "", "Comma separated priorities of tile sources, in the order -r, -d, -colors, -quantise")
+	fs.StringVar(&f.space, "space", "rgb", "Color space cells are matched to tiles in (rgb, lab, hsv, ycbcr)")
+}
+
+// mean reports whether tiles have colors beyond the web safe ones, so
+// cells should be matched on their mean color.
+func (f *tileFlags) mean() bool {
+	return f.quantise > 0 || (f.colors != "" && !strings.EqualFold(f.colors, "websafe"))
+}
+
+// colorSpace returns the color space tiles are matched in.
+func (f *tileFlags) colorSpace() palette.Space {
+	s, err := palette.ParseSpace(strings.ToLower(f.space))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
+
+// generator builds the palette generator described by the flags,
+// returning it along with the term to pass to it. im is the source
+// image used for quantisation and may be nil when there is none.
+func (f *tileFlags) generator(im image.Image) (palette.Generator, string) {
+	var dirs []string
+	if f.dirp != "" {
+		dirs = strings.Split(f.dirp, ",")
+	}
+
+	var sources []mosaic.Source
+	if f.endpoint != "" {
+		var cache string
+		if len(dirs) > 0 {
+			cache = dirs[0]
+		}
+		sources = append(sources, mosaic.Source{
+			Generator: remote.NewGenerator(f.endpoint, remote.WithCacheDir(cache)),
+			Term:      f.term,
+		})
+		// the cache holds only remote tiles, any other dirs add to them
+		if len(dirs) > 0 {
+			dirs = dirs[1:]
+		}
+	}
+	for _, dir := range dirs {
+		gen := palette.GeneratorFunc(mosaic.NewImageTilePalette)
+		if mosaic.IsSavedPalette(dir) {
+			gen = palette.GeneratorFunc(mosaic.NewSavedTilePalette)
+		}
+		sources = append(sources, mosaic.Source{
+			Generator: gen,
+			Term:      dir,
+		})
+	}
+
+	if f.colors != "" {
+		sources = append(sources, mosaic.Source{
+			Generator: palette.GeneratorFunc(mosaic.NewUniformColorsPalette),
+			Term:      f.colors,
+		})
+	}
+
+	if f.quantise > 0 && im != nil {
+		q, ok := map[string]mosaic.Quantiser{
+			"median": mosaic.MedianCut,
+			"kmeans": mosaic.KMeans,
+		}[f.quantiser]
+		if !ok {
+			log.Fatalf("Unknown quantiser %q", f.quantiser)
+		}
+		sources = append(sources, mosaic.Source{
+			Generator: mosaic.NewQuantisedPalette(im, f.quantise, q),
+		})
+	}
+
+	f.weigh(sources)
+
+	switch {
+	case len(sources) == 0:
+		return palette.GeneratorFunc(mosaic.NewUniformWebColorPalette), ""
+	case len(sources) == 1 && !f.fill:
+		return sources[0].Generator, sources[0].Term
+	}
+
+	comp := mosaic.NewCompositeGenerator(sources...)
+	if f.fill {
+		comp.WithFallback()
+	}
+	return comp, f.term
+}
+
+// weigh applies the weights and priorities given by the flags to
+// sources, in order.
+func (f *tileFlags) weigh(sources []mosaic.Source) {
+	for i, v := range perSource("-weights", f.weights, len(sources)) {
+		w, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.Fatalf("Invalid weight %q", v)
+		}
+		sources[i].Weight = w
+	}
+	for i, v := range perSource("-priorities", f.priorities, len(sources)) {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("Invalid priority %q", v)
+		}
+		sources[i].Priority = p
+	}
+}
+
+// perSource splits the comma separated values of the flag name, which
+// must have one for each of n sources unless it is empty.
+func perSource(name, values string, n int) []string {
+	if values == "" {
+		return nil
+	}
+	split := strings.Split(values, ",")
+	if len(split) != n {
+		log.Fatalf("%s has %d values for %d tile sources", name, len(split), n)
+	}
+	for i := range split {
+		split[i] = strings.TrimSpace(split[i])
+	}
+	return split
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+)
+
+// exportHTML renders a mosaic in to a directory along with a static
+// HTML page, on which every cell links to the original tile image.
+func exportHTML(args []string) {
+	var (
+		fs    = flag.NewFlagSet("export-html", flag.ExitOnError)
+		tiles tileFlags
+		alpha int
+		outp  string
+		title string
+	)
+	tiles.register(fs)
+	fs.IntVar(&alpha, "a", 255, "Alpha for masking tiles (0 to 255)")
+	fs.StringVar(&outp, "o", "mosaic", "Destination directory to write the page to")
+	fs.StringVar(&title, "title", "Mosaic", "Title of the page")
+	fs.Parse(args)
+
+	im := load(fs.Arg(0))
+
+	p, source := tiles.generator(im)
+	decoder := mosaic.NewConverter(im,
+		source,
+		mosaic.WithWidth(tiles.width),
+		mosaic.WithHeight(tiles.height),
+		mosaic.WithSize(tiles.size),
+		mosaic.WithMeanColor(tiles.mean()),
+		mosaic.WithColorSpace(tiles.colorSpace()),
+		mosaic.WithPaletteGenerator(p),
+		mosaic.WithAlpha(uint8(alpha)))
+	im, err := decoder.Decode()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(outp, "tiles"), 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	fi, err := os.Create(filepath.Join(outp, "mosaic.png"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fi.Close()
+	if err := png.Encode(fi, im); err != nil {
+		log.Fatal(err)
+	}
+
+	page, err := buildPage(title, outp, im.Bounds(), decoder.Manifest())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	index, err := os.Create(filepath.Join(outp, "index.html"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer index.Close()
+	if err := htmlTemplate.Execute(index, page); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// buildPage describes the page for a mosaic of the given bounds made up
+// of the placements m, copying tiles on disk in to outp.
+func buildPage(title, outp string, bounds image.Rectangle, m mosaic.Manifest) (htmlPage, error) {
+	page := htmlPage{Title: title}
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	copied := map[string]string{}
+	for _, pl := range m {
+		href, err := tileLink(outp, pl.Tile, copied)
+		if err != nil {
+			return page, err
+		}
+		r := pl.Rect.Sub(bounds.Min)
+		page.Cells = append(page.Cells, htmlCell{
+			Href:   href,
+			Title:  fmt.Sprintf("%s (target %s, ΔE %.1f)", tileTitle(pl.Tile, href), pl.Target, pl.DeltaE),
+			Left:   percent(r.Min.X, w),
+			Top:    percent(r.Min.Y, h),
+			Width:  percent(r.Dx(), w),
+			Height: percent(r.Dy(), h),
+		})
+	}
+	return page, nil
+}
+
+// tileLink returns the link for the tile identified by name. Tiles on
+// disk are copied in to the tiles directory under outp, remote tiles
+// are linked to directly and anything else (e.g. flat colors) has no
+// link.
+func tileLink(outp, name string, copied map[string]string) (string, error) {
+	if href, ok := copied[name]; ok {
+		return href, nil
+	}
+
+	var href string
+	switch {
+	case strings.HasPrefix(name, "http://"), strings.HasPrefix(name, "https://"):
+		href = name
+	default:
+		if info, err := os.Stat(name); err != nil || info.IsDir() {
+			break
+		}
+		href = fmt.Sprintf("tiles/%d_%s", len(copied), filepath.Base(name))
+		if err := copyFile(filepath.Join(outp, filepath.FromSlash(href)), name); err != nil {
+			return "", err
+		}
+	}
+	copied[name] = href
+	return href, nil
+}
+
+// tileTitle names a tile without revealing where it lives on the local
+// filesystem, preferring the link to it within the page.
+func tileTitle(name, href string) string {
+	switch {
+	case href != "":
+		return href
+	case name == "":
+		return name
+	}
+	return filepath.Base(name)
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func percent(v int, of float64) string {
+	return fmt.Sprintf("%.4f%%", math.Max(0, float64(v)/of*100))
+}
+
+type htmlPage struct {
+	Title string
+	Cells []htmlCell
+}
+
+type htmlCell struct {
+	Href, Title              string
+	Left, Top, Width, Height string
+}
+
+var htmlTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; background: #111; }
+.mosaic { position: relative; max-width: 100vmin; margin: 0 auto; }
+.mosaic img { display: block; width: 100%; }
+.mosaic .cell { position: absolute; box-sizing: border-box; }
+.mosaic .cell:hover { outline: 2px solid #fff; z-index: 1; }
+</style>
+</head>
+<body>
+<div class="mosaic">
+<img src="mosaic.png" alt="{{.Title}}">
+{{range .Cells}}{{if .Href}}<a class="cell" href="{{.Href}}" title="{{.Title}}" style="left: {{.Left}}; top: {{.Top}}; width: {{.Width}}; height: {{.Height}}"></a>{{else}}<span class="cell" title="{{.Title}}" style="left: {{.Left}}; top: {{.Top}}; width: {{.Width}}; height: {{.Height}}"></span>{{end}}
+{{end}}</div>
+</body>
+</html>
+`))
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+)
+
+var errmsg string = "Expected %v, Got %v\n"
+
+func TestTileTitle(t *testing.T) {
+	for _, test := range []struct {
+		name, href, expected string
+	}{
+		{"/home/someone/tiles/a.png", "tiles/0_a.png", "tiles/0_a.png"},
+		{"https://example.com/a.png", "https://example.com/a.png", "https://example.com/a.png"},
+		// tiles which couldn't be copied still hide their directory
+		{"/home/someone/tiles/gone.png", "", "gone.png"},
+		{"#ff0000", "", "#ff0000"},
+		{"", "", ""},
+	} {
+		if title := tileTitle(test.name, test.href); title != test.expected {
+			t.Errorf(errmsg, test.expected, title)
+		}
+	}
+}
+
+func TestBuildPage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomosaic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, "private", "a&b.png")
+	outp := filepath.Join(dir, "out")
+	for _, d := range []string{filepath.Dir(local), filepath.Join(outp, "tiles")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(local, []byte("tile"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := mosaic.Manifest{
+		{Rect: image.Rect(0, 0, 10, 10), Tile: local, Target: "#ff0000"},
+		{Rect: image.Rect(10, 0, 20, 10), Tile: "https://example.com/t.png?a=1&b=<2>", Target: "#00ff00"},
+		{Rect: image.Rect(0, 10, 10, 20), Tile: "#0000ff", Target: "#0000ff"},
+		{Rect: image.Rect(10, 10, 20, 20), Tile: local, Target: "#ff0000"},
+	}
+	page, err := buildPage("<script>alert(1)</script>", outp, image.Rect(0, 0, 20, 20), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the local tile is copied once and linked relative to the page
+	if href := page.Cells[0].Href; href != "tiles/0_a&b.png" || page.Cells[3].Href != href {
+		t.Errorf(errmsg, "tiles/0_a&b.png", []string{href, page.Cells[3].Href})
+	}
+	if _, err := os.Stat(filepath.Join(outp, "tiles", "0_a&b.png")); err != nil {
+		t.Error(err)
+	}
+	if c := page.Cells[1]; c.Left != "50.0000%" || c.Top != "0.0000%" || c.Width != "50.0000%" {
+		t.Errorf(errmsg, "50.0000% 0.0000% 50.0000%", []string{c.Left, c.Top, c.Width})
+	}
+	if c := page.Cells[2]; c.Href != "" || c.Title != "#0000ff (target #0000ff, ΔE 0.0)" {
+		t.Errorf(errmsg, "#0000ff (target #0000ff, ΔE 0.0)", c.Title)
+	}
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, page); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+
+	if strings.Contains(html, dir) {
+		t.Errorf("Expected no local paths in the page, Got %s\n", html)
+	}
+	for _, raw := range []string{"<script>", "<2>"} {
+		if strings.Contains(html, raw) {
+			t.Errorf("Expected %q to be escaped, Got %s\n", raw, html)
+		}
+	}
+	for _, escaped := range []string{"&lt;script&gt;", "tiles/0_a&amp;b.png", "<span class=\"cell\" title=\"#0000ff"} {
+		if !strings.Contains(html, escaped) {
+			t.Errorf("Expected %q in the page, Got %s\n", escaped, html)
+		}
+	}
+}
@@ -4,38 +4,267 @@ import (
 	"flag"
 	"image"
 	"image/draw"
-	"image/png"
 	"io"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/GeorgeMac/gomosaic/mosaic"
-	"github.com/GeorgeMac/gomosaic/mosaic/palette"
 )
 
 func main() {
-	var width, height, alpha, t int
-	var outp, dirp string
-	flag.IntVar(&width, "w", 50, "Width in number of tiles")
-	flag.IntVar(&height, "h", 50, "Height in number of tiles")
-	flag.IntVar(&t, "t", 100, "Tile size in t/t px")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "analyze":
+			analyze(os.Args[2:])
+			return
+		case "export-html":
+			exportHTML(os.Args[2:])
+			return
+		case "export-palette":
+			exportPalette(os.Args[2:])
+			return
+		case "plan":
+			plan(os.Args[2:])
+			return
+		case "reveal":
+			reveal(os.Args[2:])
+			return
+		}
+	}
+
+	var alpha, alphaMin int
+	var outp, gaps, manp, dither, term, shape, svgTiles, blend string
+	var maxd, coherence, alphaE float64
+	var seed int64
+	var delay int
+	var tiles tileFlags
+	var prints printFlags
+	var masks maskFlags
+	tiles.register(flag.CommandLine)
+	prints.register(flag.CommandLine)
+	masks.register(flag.CommandLine)
 	flag.IntVar(&alpha, "a", 255, "Alpha for masking tiles (0 to 255)")
+	flag.IntVar(&alphaMin, "alpha-min", 0, "Alpha for the worst matched tiles with adaptive alpha (0 to 255)")
+	flag.Float64Var(&alphaE, "alpha-e", 0, "ΔE at which tiles fall to -alpha-min (0 disables adaptive alpha)")
+	flag.StringVar(&blend, "blend", "normal", "Blend mode for tiles (normal, multiply, overlay, soft-light, luminosity, color)")
 	flag.StringVar(&outp, "o", "", "Destination path to write file to (otherwise STDOUT)")
-	flag.StringVar(&dirp, "d", "", "Location of images to use as tiles")
+	flag.StringVar(&gaps, "g", "nearest", "Policy for colors without a close tile (nearest, color, error)")
+	flag.Float64Var(&maxd, "m", math.Inf(1), "Max color distance before a cell is treated as a gap")
+	flag.StringVar(&dither, "dither", "none", "Diffuse color error between cells (none, fs, atkinson)")
+	flag.Int64Var(&seed, "seed", 0, "Seed for deterministic tile selection (otherwise tiles of the same color are used in turn, in the order cells happen to be matched)")
+	flag.StringVar(&manp, "manifest", "", "Destination path to write the tile manifest to (.csv or .json)")
+	flag.StringVar(&shape, "shape", "rect", "Shape of each cell in SVG output (rect, circle)")
+	flag.StringVar(&svgTiles, "svg-tiles", "none", "How SVG output includes image tiles (none, link, embed)")
+	flag.Float64Var(&coherence, "coherence", 0, "Keep a cell's tile between animation frames while its color stays within this ΔE")
+	flag.IntVar(&delay, "delay", 10, "Delay between frames read from a directory in 1/100s")
+	flag.StringVar(&term, "term", "", "Preview the mosaic as text instead of an image (ansi, ascii)")
 	flag.Parse()
 
-	path := flag.Args()[0]
+	policy, ok := map[string]mosaic.GapPolicy{
+		"nearest": mosaic.GapNearest,
+		"color":   mosaic.GapFallbackColor,
+		"error":   mosaic.GapError,
+	}[gaps]
+	if !ok {
+		log.Fatalf("Unknown gap policy %q", gaps)
+	}
+
+	kernel, ok := map[string]mosaic.DitherKernel{
+		"none":     mosaic.DitherNone,
+		"fs":       mosaic.DitherFloydSteinberg,
+		"atkinson": mosaic.DitherAtkinson,
+	}[dither]
+	if !ok {
+		log.Fatalf("Unknown dither kernel %q", dither)
+	}
+
+	prints.grid(&tiles)
+
+	mode, ok := mosaic.BlendModes[blend]
+	if !ok {
+		log.Fatalf("Unknown blend mode %q", blend)
+	}
+
+	crop := prints.crop(tiles)
+	var im image.Image
+	anim := loadAnimation(flag.Args()[0], delay, crop)
+	if anim != nil {
+		im = anim.Frames[0]
+	} else {
+		im = crop(decode(flag.Args()[0]))
+	}
+
+	p, source := tiles.generator(im)
+	decoder := mosaic.NewConverter(im,
+		source,
+		mosaic.WithWidth(tiles.width),
+		mosaic.WithHeight(tiles.height),
+		mosaic.WithSize(tiles.size),
+		mosaic.WithMeanColor(tiles.mean() || kernel != mosaic.DitherNone),
+		mosaic.WithColorSpace(tiles.colorSpace()),
+		mosaic.WithPaletteGenerator(p),
+		mosaic.WithGapPolicy(policy),
+		mosaic.WithMaxDistance(maxd),
+		mosaic.WithDithering(kernel),
+		mosaic.WithCoherence(coherence),
+		mosaic.WithBlendMode(mode),
+		mosaic.WithAdaptiveAlpha(uint8(alphaMin), alphaE),
+		mosaic.WithAlpha(uint8(alpha)),
+		seeded(seed))
+	masks.apply(decoder, im, crop)
+
+	if anim != nil {
+		animate(decoder, anim, outp)
+		return
+	}
+
+	if term != "" {
+		preview(decoder, term, outp)
+		return
+	}
+
+	if strings.ToLower(filepath.Ext(outp)) == ".svg" {
+		encodeSVG(decoder, outp, shape, svgTiles)
+		if manp != "" {
+			writeManifest(manp, decoder.Manifest())
+		}
+		return
+	}
+
+	im, err := decoder.Decode()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, closer := create(outp)
+	defer closer()
+
+	if err := prints.encode(out, outp, prints.finish(im)); err != nil {
+		log.Fatal(err)
+	}
+
+	if manp != "" {
+		writeManifest(manp, decoder.Manifest())
+	}
+}
+
+// seeded returns an option seeding tile selection when -seed was given,
+// which may be any value including 0, and otherwise does nothing.
+func seeded(seed int64) func(*mosaic.Converter) {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == "seed"
+	})
+	if !set {
+		return func(*mosaic.Converter) {}
+	}
+	return mosaic.WithSeed(seed)
+}
+
+// preview writes the tile colors chosen by decoder to path as text in
+// the given mode.
+func preview(decoder *mosaic.Converter, mode, path string) {
+	if mode != "ansi" && mode != "ascii" {
+		log.Fatalf("Unknown preview mode %q", mode)
+	}
+
+	grid, err := decoder.Grid()
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	out, closer := create(path)
+	defer closer()
+
+	write := grid.WriteANSI
+	if mode == "ascii" {
+		write = grid.WriteASCII
+	}
+	if err := write(out); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// encodeSVG writes the mosaic described by decoder to path as SVG.
+func encodeSVG(decoder *mosaic.Converter, path, shape, tiles string) {
+	s, ok := map[string]mosaic.SVGShape{
+		"rect":   mosaic.SVGRect,
+		"circle": mosaic.SVGCircle,
+	}[shape]
+	if !ok {
+		log.Fatalf("Unknown shape %q", shape)
+	}
+
+	t, ok := map[string]mosaic.SVGTiles{
+		"none":  mosaic.SVGTilesNone,
+		"link":  mosaic.SVGTilesLink,
+		"embed": mosaic.SVGTilesEmbed,
+	}[tiles]
+	if !ok {
+		log.Fatalf("Unknown SVG tile mode %q", tiles)
+	}
+
+	mosaic.WithLinkDir(filepath.Dir(path))(decoder)
+	write(path, func(w io.Writer) error {
+		return decoder.EncodeSVG(w, s, t)
+	})
+}
+
+// writeManifest writes m to path as CSV or JSON depending on the
+// extension of path.
+func writeManifest(path string, m mosaic.Manifest) {
+	fi, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fi.Close()
+
+	write := m.WriteJSON
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		write = m.WriteCSV
+	}
+	if err := write(fi); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// write creates path and writes to it with fn.
+func write(path string, fn func(io.Writer) error) {
+	fi, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fi.Close()
+	if err := fn(fi); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// load decodes the image at path and crops it to a square.
+func load(path string) image.Image {
+	return square(decode(path))
+}
+
+// decode reads the image at path.
+func decode(path string) image.Image {
 	fi, err := os.Open(path)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer fi.Close()
 
-	im, _, err := image.Decode(fi)
+	im, _, err := mosaic.DecodeImage(fi)
 	if err != nil {
 		log.Fatal("Decoding Error: ", err)
 	}
+	return im
+}
 
+// square crops im to a square.
+func square(im image.Image) image.Image {
 	bounds := im.Bounds()
 	var w, x, y int
 	x, y = bounds.Dx(), bounds.Dy()
@@ -48,39 +277,22 @@ func main() {
 	rgbaim := image.NewRGBA(rect)
 	draw.Draw(rgbaim, rect, im, image.ZP, draw.Src)
 
-	im, err = mosaic.Resize(rgbaim, w, w)
+	im, err := mosaic.Resize(rgbaim, w, w)
 	if err != nil {
 		log.Fatal("Tiling Error: ", err)
 	}
+	return im
+}
 
-	p := palette.GeneratorFunc(mosaic.NewUniformWebColorPalette)
-	if dirp != "" {
-		p = palette.GeneratorFunc(mosaic.NewImageTilePalette)
+// create opens path for writing, or STDOUT when path is empty. The
+// returned func closes the file.
+func create(path string) (io.Writer, func()) {
+	if path == "" {
+		return os.Stdout, func() {}
 	}
-	decoder := mosaic.NewConverter(im,
-		dirp,
-		mosaic.WithWidth(width),
-		mosaic.WithHeight(height),
-		mosaic.WithSize(t),
-		mosaic.WithPaletteGenerator(p),
-		mosaic.WithAlpha(uint8(alpha)))
-	im, err = decoder.Decode()
+	fi, err := os.Create(path)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	var out io.Writer = os.Stdout
-	if outp != "" {
-		var err error
-		fi, err := os.Create(outp)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer fi.Close()
-		out = fi
-	}
-
-	if err := png.Encode(out, im); err != nil {
-		log.Fatal(err)
-	}
+	return fi, func() { fi.Close() }
 }
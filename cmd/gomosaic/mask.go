@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"log"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+)
+
+// maskFlags describe a focus region picked out by a grayscale mask.
+type maskFlags struct {
+	path              string
+	min, split, alpha int
+	original          bool
+}
+
+func (f *maskFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "mask", "", "Grayscale mask marking the focus region, or auto to detect detailed areas")
+	fs.IntVar(&f.min, "focus-min", 128, "Mask value (0 to 255) from which cells are in the focus region")
+	fs.IntVar(&f.split, "focus-split", 1, "Split cells in the focus region in to NxN smaller tiles")
+	fs.IntVar(&f.alpha, "focus-alpha", 0, "Alpha for tiles in the focus region (0 keeps -a)")
+	fs.BoolVar(&f.original, "focus-original", false, "Leave the focus region as the source image")
+}
+
+// apply masks decoder, converting im, with the focus region. A mask
+// loaded from disk is cropped by crop as the source was. It does nothing
+// unless a mask was given.
+func (f *maskFlags) apply(decoder *mosaic.Converter, im image.Image, crop func(image.Image) image.Image) {
+	if f.path == "" {
+		return
+	}
+	if f.min < 0 || f.min > 255 || f.alpha < 0 || f.alpha > 255 {
+		log.Fatal("-focus-min and -focus-alpha must be between 0 and 255")
+	}
+
+	var mask image.Image
+	if f.path == "auto" {
+		mask = mosaic.DetailMask(im)
+	} else {
+		mask = crop(decode(f.path))
+	}
+
+	mosaic.WithMask(mask, mosaic.Region{
+		Min:       uint8(f.min),
+		Max:       255,
+		Subdivide: f.split,
+		Alpha:     uint8(f.alpha),
+		Original:  f.original,
+	})(decoder)
+}
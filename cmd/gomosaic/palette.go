@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"log"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+)
+
+// exportPalette builds the palette described by the tile flags and
+// saves it, so it can be shipped and reused via -d without rebuilding.
+// Quantised palettes are drawn from the image given as an argument.
+func exportPalette(args []string) {
+	var (
+		fs    = flag.NewFlagSet("export-palette", flag.ExitOnError)
+		tiles tileFlags
+		outp  string
+	)
+	tiles.register(fs)
+	fs.StringVar(&outp, "o", "palette", "Destination directory to save the palette to")
+	fs.Parse(args)
+
+	var im image.Image
+	switch {
+	case fs.NArg() > 0:
+		im = load(fs.Arg(0))
+	case tiles.quantise > 0:
+		log.Fatal("-quantise needs an image to quantise, e.g. export-palette -quantise 16 photo.jpg")
+	}
+
+	gen, term := tiles.generator(im)
+	p, err := gen.Palette(term, tiles.size)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var tp *mosaic.TilePalette
+	switch p := p.(type) {
+	case *mosaic.TilePalette:
+		tp = p
+	case *mosaic.AtlasPalette:
+		tp = p.TilePalette
+	default:
+		log.Fatalf("Palette of type %T cannot be saved, use a single tile source", p)
+	}
+
+	if err := mosaic.SavePalette(outp, tp); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+)
+
+// plan builds a physical mosaic from a list of materials and their
+// stock, writing a cell by cell build plan and a bill of materials.
+func plan(args []string) {
+	var (
+		fs                = flag.NewFlagSet("plan", flag.ExitOnError)
+		width, height     int
+		matp, outp, gridp string
+		billp, previewp   string
+		size              int
+	)
+	fs.IntVar(&width, "w", 50, "Width in number of pieces")
+	fs.IntVar(&height, "h", 50, "Height in number of pieces")
+	fs.StringVar(&matp, "m", "", "CSV of materials (code,name,color,quantity)")
+	fs.StringVar(&outp, "o", "", "Destination path to write the CSV build plan to (otherwise STDOUT)")
+	fs.StringVar(&gridp, "grid", "", "Destination path to write a printable grid of material codes to")
+	fs.StringVar(&billp, "bom", "", "Destination path to write the CSV bill of materials to")
+	fs.StringVar(&previewp, "preview", "", "Destination path to write a PNG preview of the plan to")
+	fs.IntVar(&size, "t", 10, "Size of each piece in the preview in t/t px")
+	fs.Parse(args)
+
+	if matp == "" {
+		log.Fatal("A materials file is required (-m)")
+	}
+	materials, err := mosaic.LoadMaterials(matp)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	im := load(fs.Arg(0))
+
+	decoder := mosaic.NewConverter(im, "",
+		mosaic.WithWidth(width),
+		mosaic.WithHeight(height))
+	p, err := decoder.Plan(materials)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, closer := create(outp)
+	defer closer()
+	if err := p.WriteCSV(out); err != nil {
+		log.Fatal(err)
+	}
+
+	if gridp != "" {
+		write(gridp, p.WriteGrid)
+	}
+	if billp != "" {
+		write(billp, p.WriteBill)
+	}
+	if previewp != "" {
+		fi, err := os.Create(previewp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer fi.Close()
+		if err := png.Encode(fi, p.Image(size)); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
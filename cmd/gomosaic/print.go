@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+)
+
+// printFlags describe the physical size a mosaic is printed at.
+type printFlags struct {
+	size, bleed string
+	dpi         float64
+	marks       bool
+
+	print *mosaic.PrintSize
+}
+
+func (f *printFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.size, "print", "", "Physical print size, e.g. 60x90cm or 24x36in (derives -t and -h from -w)")
+	fs.Float64Var(&f.dpi, "dpi", 300, "Print resolution in dots per inch")
+	fs.StringVar(&f.bleed, "bleed", "", "Bleed added around the print, e.g. 3mm")
+	fs.BoolVar(&f.marks, "marks", false, "Add crop marks around the print")
+}
+
+// grid parses the print size, deriving the tile size and number of rows
+// of tiles from it. It does nothing unless a print size was given.
+func (f *printFlags) grid(tiles *tileFlags) {
+	if f.size == "" {
+		return
+	}
+
+	p, err := mosaic.ParsePrintSize(f.size, f.dpi)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f.print = &p
+	tiles.width, tiles.height, tiles.size = p.Grid(tiles.width)
+	log.Printf("Printing %s at %g dpi with %dx%d tiles of %dpx\n", f.size, f.dpi, tiles.width, tiles.height, tiles.size)
+}
+
+// crop returns a func cropping source images to the aspect ratio of the
+// grid of tiles when printing, and otherwise to squares.
+func (f *printFlags) crop(tiles tileFlags) func(image.Image) image.Image {
+	if f.print == nil {
+		return square
+	}
+	return func(im image.Image) image.Image {
+		return mosaic.CropAspect(im, tiles.width, tiles.height)
+	}
+}
+
+// finish pads im to the print size and adds any bleed and crop marks.
+// The bleed extends the artwork itself, so it is added before padding.
+func (f *printFlags) finish(im image.Image) image.Image {
+	if f.print == nil {
+		return im
+	}
+
+	var bleed int
+	if f.bleed != "" {
+		v, err := mosaic.ParseLength(f.bleed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		bleed = f.print.Inches(v)
+	}
+
+	if bleed > 0 {
+		im = f.print.FitBleed(im, bleed)
+	} else {
+		im = f.print.Fit(im)
+	}
+
+	if f.marks {
+		// a third of an inch leaves room for marks clear of the bleed
+		im = mosaic.CropMarks(im, bleed, bleed+f.print.Inches(1.0/3))
+	}
+	return im
+}
+
+// encode writes im to w as a JPEG or PNG depending on the extension of
+// path, recording the print resolution when printing.
+func (f *printFlags) encode(w io.Writer, path string, im image.Image) error {
+	jpg := false
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		jpg = true
+	}
+
+	switch {
+	case f.print == nil && jpg:
+		return jpeg.Encode(w, im, &jpeg.Options{Quality: 95})
+	case f.print == nil:
+		return png.Encode(w, im)
+	case jpg:
+		return mosaic.EncodeJPEG(w, im, f.dpi, &jpeg.Options{Quality: 95})
+	}
+	return mosaic.EncodePNG(w, im, f.dpi)
+}
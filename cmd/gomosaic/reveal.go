@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+)
+
+// reveal renders an animation revealing the mosaic, either zooming out
+// from the tile at its centre or crossfading from the source image.
+func reveal(args []string) {
+	var (
+		fs         = flag.NewFlagSet("reveal", flag.ExitOnError)
+		tiles      tileFlags
+		alpha      int
+		outp, mode string
+		ease       string
+		size, fps  int
+		duration   time.Duration
+		hold       time.Duration
+	)
+	tiles.register(fs)
+	fs.IntVar(&alpha, "a", 255, "Alpha for masking tiles (0 to 255)")
+	fs.StringVar(&outp, "o", "reveal.gif", "Destination .gif, or directory to write numbered frames to")
+	fs.StringVar(&mode, "mode", "zoom", "Reveal by zooming out from a tile or fading from the source (zoom, fade)")
+	fs.StringVar(&ease, "ease", "ease-in-out", "Easing of the reveal (linear, ease-in, ease-out, ease-in-out)")
+	fs.IntVar(&size, "size", 480, "Length of the longest side of each frame in px")
+	fs.IntVar(&fps, "fps", 15, "Frames per second")
+	fs.DurationVar(&duration, "duration", 3*time.Second, "Duration of the reveal")
+	fs.DurationVar(&hold, "hold", time.Second, "How long to hold the final frame")
+	fs.Parse(args)
+
+	easing, ok := mosaic.Easings[ease]
+	if !ok {
+		log.Fatalf("Unknown easing %q", ease)
+	}
+	if mode != "zoom" && mode != "fade" {
+		log.Fatalf("Unknown reveal mode %q", mode)
+	}
+
+	im := mosaic.CropAspect(decode(fs.Arg(0)), tiles.width, tiles.height)
+
+	p, source := tiles.generator(im)
+	decoder := mosaic.NewConverter(im,
+		source,
+		mosaic.WithWidth(tiles.width),
+		mosaic.WithHeight(tiles.height),
+		mosaic.WithSize(tiles.size),
+		mosaic.WithMeanColor(tiles.mean()),
+		mosaic.WithColorSpace(tiles.colorSpace()),
+		mosaic.WithPaletteGenerator(p),
+		mosaic.WithAlpha(uint8(alpha)))
+	out, err := decoder.Decode()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := mosaic.Reveal{
+		Duration: duration,
+		FPS:      fps,
+		Hold:     hold,
+		Size:     frameSize(out.Bounds().Size(), size),
+		Easing:   easing,
+	}
+
+	var anim *mosaic.Animation
+	if mode == "fade" {
+		anim, err = r.Crossfade(im, out)
+	} else {
+		b := out.Bounds()
+		focus, _ := decoder.Manifest().At(image.Pt((b.Min.X+b.Max.X)/2, (b.Min.Y+b.Max.Y)/2))
+		anim, err = r.Zoom(out, focus.Rect)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if strings.ToLower(filepath.Ext(outp)) == ".gif" {
+		write(outp, func(w io.Writer) error {
+			return anim.EncodeGIF(w)
+		})
+		return
+	}
+	if err := anim.WriteFrames(outp); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// frameSize returns the size of frames with the aspect ratio of a mosaic
+// of the given size, whose longest side is longest.
+func frameSize(mosaic image.Point, longest int) image.Point {
+	size := image.Pt(longest, longest)
+	if mosaic.X > mosaic.Y {
+		size.Y = longest * mosaic.Y / mosaic.X
+	} else {
+		size.X = longest * mosaic.X / mosaic.Y
+	}
+	if size.X < 1 {
+		size.X = 1
+	}
+	if size.Y < 1 {
+		size.Y = 1
+	}
+	return size
+}
@@ -7,6 +7,8 @@ import (
 	"image/png"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/GeorgeMac/gomosaic/mosaic"
 
@@ -16,8 +18,16 @@ import (
 
 func main() {
 	var size int
+	var atlas bool
 	flag.IntVar(&size, "s", 50, "Tile Output Size")
+	flag.BoolVar(&atlas, "atlas", false, "Pack every image in the sources (files or directories) in to an atlas: gotile -atlas dst src...")
 	flag.Parse()
+
+	if atlas {
+		pack(size, flag.Arg(0), flag.Args()[1:])
+		return
+	}
+
 	src, dst := flag.Arg(0), flag.Arg(1)
 
 	srcfi, err := os.Open(src)
@@ -32,7 +42,7 @@ func main() {
 	}
 	defer dstfi.Close()
 
-	srcim, _, err := image.Decode(srcfi)
+	srcim, _, err := mosaic.DecodeImage(srcfi)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -51,3 +61,63 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// tile crops im to its centre square and scales it to size/size px.
+func tile(im image.Image, size int) (image.Image, error) {
+	bounds := im.Bounds()
+	rect := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+	copyim := image.NewRGBA(rect)
+	draw.Draw(copyim, rect, im, bounds.Min, draw.Src)
+
+	return mosaic.Resize(copyim, size, size)
+}
+
+// pack scales every image found in srcs and packs them in to an atlas
+// written to dst, which can be used directly as a gomosaic tile source.
+func pack(size int, dst string, srcs []string) {
+	a := mosaic.NewAtlas(size)
+	add := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".gif", ".jpg", ".jpeg", ".png":
+		default:
+			return nil
+		}
+
+		fi, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer fi.Close()
+
+		im, _, err := mosaic.DecodeImage(fi)
+		if err != nil {
+			log.Printf("Skipping %s: %s\n", path, err)
+			return nil
+		}
+
+		scaled, err := tile(im, size)
+		if err != nil {
+			log.Printf("Skipping %s: %s\n", path, err)
+			return nil
+		}
+
+		t := mosaic.NewImageTile(scaled)
+		t.Source = path
+		return a.Add(t)
+	}
+
+	for _, src := range srcs {
+		if err := filepath.Walk(src, add); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := a.Save(dst); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Packed %d tiles in to %d sheets\n", len(a.Entries), len(a.Sheets))
+}
@@ -0,0 +1,164 @@
+package mosaic
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// Report describes how well a palette covers the colors of a source
+// image, as produced by Converter.Analyze.
+type Report struct {
+	Cells         int          `json:"cells"`
+	Threshold     float64      `json:"threshold"`
+	Coverage      float64      `json:"coverage"`
+	AverageDeltaE float64      `json:"average_delta_e"`
+	Worst         []CellMatch  `json:"worst"`
+	Suggestions   []Suggestion `json:"suggestions"`
+
+	cells []CellMatch
+}
+
+// CellMatch is the result of matching a single cell of the source
+// image against the palette.
+type CellMatch struct {
+	Rect   image.Rectangle `json:"rect"`
+	Target string          `json:"target"`
+	Match  string          `json:"match"`
+	DeltaE float64         `json:"delta_e"`
+}
+
+// Suggestion is a color missing from the palette, along with the
+// number of cells which would benefit from a tile of that color.
+type Suggestion struct {
+	Color string `json:"color"`
+	Cells int    `json:"cells"`
+}
+
+// Analyze matches every cell of the source image against the palette
+// without rendering. Cells within threshold (ΔE) of their tile count
+// as covered; the n worst matched cells and the n most needed missing
+// colors are included in the report.
+func (d *Converter) Analyze(threshold float64, n int) (*Report, error) {
+	p, err := d.generator.Palette(d.term, d.size)
+	if err != nil {
+		return nil, err
+	}
+	matchIn(p, d.space)
+
+	proc := make(chan []cell, d.procBuffer)
+	go d.bounds(proc, d.im.Bounds())
+
+	var (
+		imtile  = NewImageTile(d.im)
+		report  = &Report{Threshold: threshold}
+		missing = map[palette.ColorKey]int{}
+		covered int
+		total   float64
+	)
+
+	for cell := range cells(proc) {
+		c := d.cellColor(imtile, cell.Rect)
+		match := CellMatch{Rect: cell.Rect, Target: Hex(c)}
+
+		var dist float64
+		if tile := d.pick(p, c, cell.Index); tile != nil {
+			match.Match = Hex(tile)
+			dist = palette.DeltaE(c, tile)
+		} else {
+			dist = palette.DeltaE(color.Black, color.White)
+		}
+		match.DeltaE = dist
+
+		total += dist
+		if dist <= threshold {
+			covered++
+		} else {
+			missing[palette.NewColorKey(WebSafe.Convert(c))]++
+		}
+		report.cells = append(report.cells, match)
+	}
+
+	report.Cells = len(report.cells)
+	if report.Cells == 0 {
+		return report, nil
+	}
+	report.Coverage = float64(covered) / float64(report.Cells)
+	report.AverageDeltaE = total / float64(report.Cells)
+
+	worst := make([]CellMatch, len(report.cells))
+	copy(worst, report.cells)
+	sort.Stable(byDeltaE(worst))
+	if len(worst) > n {
+		worst = worst[:n]
+	}
+	report.Worst = worst
+
+	for k, v := range missing {
+		report.Suggestions = append(report.Suggestions, Suggestion{
+			Color: Hex(k.RGBA64()),
+			Cells: v,
+		})
+	}
+	sort.Sort(byCells(report.Suggestions))
+	if len(report.Suggestions) > n {
+		report.Suggestions = report.Suggestions[:n]
+	}
+
+	return report, nil
+}
+
+// Heatmap renders the ΔE of each cell within bounds, from green for an
+// exact match through yellow at the threshold to red at twice it.
+func (r *Report) Heatmap(bounds image.Rectangle) image.Image {
+	dst := image.NewRGBA(bounds)
+	for _, cell := range r.cells {
+		t := 1.0
+		if r.Threshold > 0 {
+			t = cell.DeltaE / (2 * r.Threshold)
+		}
+		draw.Draw(dst, cell.Rect, image.NewUniform(ramp(t)), image.ZP, draw.Src)
+	}
+	return dst
+}
+
+// ramp maps t in [0, 1] on to a green, yellow, red gradient.
+func ramp(t float64) color.Color {
+	switch {
+	case t < 0:
+		t = 0
+	case t > 1:
+		t = 1
+	}
+	if t < 0.5 {
+		return color.RGBA{uint8(510 * t), 255, 0, 255}
+	}
+	return color.RGBA{255, uint8(510 * (1 - t)), 0, 255}
+}
+
+// Hex formats c as a #rrggbb string.
+func Hex(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+type byDeltaE []CellMatch
+
+func (b byDeltaE) Len() int           { return len(b) }
+func (b byDeltaE) Less(i, j int) bool { return b[i].DeltaE > b[j].DeltaE }
+func (b byDeltaE) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+type byCells []Suggestion
+
+func (b byCells) Len() int { return len(b) }
+func (b byCells) Less(i, j int) bool {
+	if b[i].Cells == b[j].Cells {
+		return b[i].Color < b[j].Color
+	}
+	return b[i].Cells > b[j].Cells
+}
+func (b byCells) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,77 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+func TestConverter_Analyze(t *testing.T) {
+	// the palette covers every quadrant but the yellow one
+	var tiles []palette.Tile
+	for _, c := range []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}} {
+		tiles = append(tiles, &UniformTile{Uniform: image.NewUniform(c)})
+	}
+	d := NewConverter(quads(20), "",
+		WithWidth(2),
+		WithHeight(2),
+		WithSize(10),
+		WithPaletteGenerator(tilePalette(tiles)))
+
+	report, err := d.Analyze(10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Cells != 4 {
+		t.Errorf(errmsg, 4, report.Cells)
+	}
+	if report.Coverage != 0.75 {
+		t.Errorf(errmsg, 0.75, report.Coverage)
+	}
+
+	if len(report.Worst) != 1 {
+		t.Fatalf(errmsg, 1, len(report.Worst))
+	}
+	worst := report.Worst[0]
+	if worst.Rect != image.Rect(10, 10, 20, 20) || worst.Target != "#ffff00" {
+		t.Errorf(errmsg, "#ffff00 at (10,10)-(20,20)", worst)
+	}
+	// yellow is equally far from red and green, so may match either
+	match, ok := map[string]color.RGBA{
+		"#ff0000": {255, 0, 0, 255},
+		"#00ff00": {0, 255, 0, 255},
+	}[worst.Match]
+	if !ok {
+		t.Fatalf(errmsg, "#ff0000 or #00ff00", worst.Match)
+	}
+	if expected := palette.DeltaE(color.RGBA{255, 255, 0, 255}, match); worst.DeltaE != expected || worst.DeltaE <= 10 {
+		t.Errorf(errmsg, expected, worst.DeltaE)
+	}
+	if expected := worst.DeltaE / 4; report.AverageDeltaE != expected {
+		t.Errorf(errmsg, expected, report.AverageDeltaE)
+	}
+
+	if expected := []Suggestion{{Color: "#ffff00", Cells: 1}}; len(report.Suggestions) != 1 || report.Suggestions[0] != expected[0] {
+		t.Errorf(errmsg, expected, report.Suggestions)
+	}
+
+	// covered cells are green, the yellow cell far beyond the threshold
+	// is red
+	heat := report.Heatmap(image.Rect(0, 0, 20, 20))
+	for _, tc := range []struct {
+		x, y int
+		c    color.RGBA
+	}{
+		{5, 5, color.RGBA{0, 255, 0, 255}},
+		{15, 5, color.RGBA{0, 255, 0, 255}},
+		{5, 15, color.RGBA{0, 255, 0, 255}},
+		{15, 15, color.RGBA{255, 0, 0, 255}},
+	} {
+		if c := heat.At(tc.x, tc.y); c != tc.c {
+			t.Errorf("(%d, %d): "+errmsg, tc.x, tc.y, tc.c, c)
+		}
+	}
+}
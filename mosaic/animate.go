@@ -0,0 +1,271 @@
+package mosaic
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// Animation is a sequence of frames with the delay after each one in
+// hundredths of a second, as used by GIF.
+type Animation struct {
+	Frames    []image.Image
+	Delays    []int
+	LoopCount int
+}
+
+// DecodeAnimation reads every frame of a GIF. Frames are composed on to
+// the full canvas, honouring each frame's disposal method, so every
+// frame is complete.
+func DecodeAnimation(r io.Reader) (*Animation, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+
+	a := &Animation{Delays: g.Delay, LoopCount: g.LoopCount}
+	canvas := image.NewRGBA(bounds)
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		if i < len(g.Disposal) && g.Disposal[i] == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		snapshot := image.NewRGBA(bounds)
+		copy(snapshot.Pix, canvas.Pix)
+		a.Frames = append(a.Frames, snapshot)
+
+		if i >= len(g.Disposal) {
+			continue
+		}
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.ZP, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return a, nil
+}
+
+// LoadFrames reads every image in dir as a frame, ordered by file name,
+// each shown for delay hundredths of a second.
+func LoadFrames(dir string, delay int) (*Animation, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, info := range infos {
+		switch strings.ToLower(filepath.Ext(info.Name())) {
+		case ".gif", ".jpg", ".jpeg", ".png":
+			if !info.IsDir() {
+				names = append(names, info.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+
+	a := &Animation{}
+	for _, name := range names {
+		fi, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		im, _, err := DecodeImage(fi)
+		fi.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		a.Frames = append(a.Frames, im)
+		a.Delays = append(a.Delays, delay)
+	}
+
+	if len(a.Frames) == 0 {
+		return nil, NoFrames{Dir: dir}
+	}
+	return a, nil
+}
+
+// DecodeAnimation renders every frame of a as a mosaic. The palette is
+// generated once and shared by every frame; see WithCoherence to keep
+// tiles steady between frames.
+func (d *Converter) DecodeAnimation(a *Animation) (*Animation, error) {
+	p, err := d.generator.Palette(d.term, d.size)
+	if err != nil {
+		return nil, err
+	}
+
+	im, generator := d.im, d.generator
+	defer func() {
+		d.im, d.generator, d.history = im, generator, nil
+	}()
+
+	d.generator = palette.GeneratorFunc(func(term string, size int) (palette.Palette, error) {
+		if size != d.size {
+			// masks may need palettes at other sizes
+			return generator.Palette(term, size)
+		}
+		return p, nil
+	})
+	if d.coherence > 0 {
+		d.history = &history{threshold: d.coherence, held: map[int]held{}}
+	}
+
+	out := &Animation{Delays: a.Delays, LoopCount: a.LoopCount}
+	for _, frame := range a.Frames {
+		d.im = frame
+		mosaic, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		out.Frames = append(out.Frames, mosaic)
+	}
+	return out, nil
+}
+
+// history holds the tile matched to each cell across the frames of an
+// animation.
+type history struct {
+	threshold float64
+	mu        sync.Mutex
+	held      map[int]held
+}
+
+// held is a tile along with the color of the cell it was matched to.
+type held struct {
+	color color.Color
+	image image.Image
+}
+
+// get returns the tile previously matched to the cell at index, if the
+// cell's color c is still within the threshold (ΔE) of the color it was
+// matched for.
+func (f *history) get(index int, c color.Color) image.Image {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if h, ok := f.held[index]; ok && palette.DeltaE(h.color, c) <= f.threshold {
+		return h.image
+	}
+	return nil
+}
+
+// put records im as the tile matched to the cell at index of color c.
+func (f *history) put(index int, c color.Color, im image.Image) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	f.held[index] = held{color: c, image: im}
+	f.mu.Unlock()
+}
+
+// EncodeGIF writes a as an animated GIF. Every frame shares one palette
+// of up to 256 colors quantised from all of the frames with MedianCut,
+// and is dithered in to it with Floyd-Steinberg.
+func (a *Animation) EncodeGIF(w io.Writer) error {
+	if len(a.Frames) == 0 {
+		return NoFrames{}
+	}
+
+	p := MedianCut(stack(a.Frames), 256)
+	g := &gif.GIF{LoopCount: a.LoopCount}
+	for i, frame := range a.Frames {
+		bounds := frame.Bounds()
+		paletted := image.NewPaletted(image.Rectangle{Max: bounds.Size()}, p)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), frame, bounds.Min)
+		g.Image = append(g.Image, paletted)
+
+		delay := 10
+		if i < len(a.Delays) {
+			delay = a.Delays[i]
+		}
+		g.Delay = append(g.Delay, delay)
+	}
+	return gif.EncodeAll(w, g)
+}
+
+// WriteFrames writes each frame of a to dir as a numbered PNG, e.g.
+// frame-0001.png.
+func (a *Animation) WriteFrames(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i, frame := range a.Frames {
+		if err := writePNG(filepath.Join(dir, fmt.Sprintf("frame-%04d.png", i+1)), frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stacked presents a sequence of images as one, each below the last,
+// without copying them.
+type stacked struct {
+	images []image.Image
+	bounds image.Rectangle
+}
+
+func stack(images []image.Image) image.Image {
+	s := &stacked{images: images}
+	for _, im := range images {
+		size := im.Bounds().Size()
+		if size.X > s.bounds.Max.X {
+			s.bounds.Max.X = size.X
+		}
+		s.bounds.Max.Y += size.Y
+	}
+	return s
+}
+
+func (s *stacked) ColorModel() color.Model { return color.RGBAModel }
+func (s *stacked) Bounds() image.Rectangle { return s.bounds }
+
+func (s *stacked) At(x, y int) color.Color {
+	for _, im := range s.images {
+		b := im.Bounds()
+		if y < b.Dy() {
+			if x >= b.Dx() {
+				return color.Transparent
+			}
+			return im.At(b.Min.X+x, b.Min.Y+y)
+		}
+		y -= b.Dy()
+	}
+	return color.Transparent
+}
+
+// errors
+
+type NoFrames struct {
+	Dir string
+}
+
+func (n NoFrames) Error() string {
+	if n.Dir == "" {
+		return "animation contains no frames"
+	}
+	return fmt.Sprintf("no frames found in %s", n.Dir)
+}
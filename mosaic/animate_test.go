@@ -0,0 +1,169 @@
+package mosaic
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+func TestDecodeAnimation(t *testing.T) {
+	p := color.Palette{color.Transparent, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}}
+	full := image.NewPaletted(image.Rect(0, 0, 4, 4), p)
+	for i := range full.Pix {
+		full.Pix[i] = 1
+	}
+	// a partial frame drawn over the first, then disposed
+	part := image.NewPaletted(image.Rect(2, 2, 4, 4), p)
+	for i := range part.Pix {
+		part.Pix[i] = 2
+	}
+	// a transparent frame revealing what lies beneath
+	empty := image.NewPaletted(image.Rect(0, 0, 1, 1), p)
+
+	buf := &bytes.Buffer{}
+	if err := gif.EncodeAll(buf, &gif.GIF{
+		Image:    []*image.Paletted{full, part, empty},
+		Delay:    []int{5, 10, 15},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+		Config:   image.Config{ColorModel: p, Width: 4, Height: 4},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := DecodeAnimation(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.Frames) != 3 || a.Delays[2] != 15 {
+		t.Fatalf(errmsg, "3 frames", a)
+	}
+
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	for _, tc := range []struct {
+		frame, x, y int
+		c           color.Color
+	}{
+		{0, 3, 3, red},
+		{1, 0, 0, red},
+		{1, 3, 3, blue},
+		// the blue frame was disposed to the background
+		{2, 0, 0, red},
+		{2, 3, 3, color.RGBA{}},
+	} {
+		if c := a.Frames[tc.frame].At(tc.x, tc.y); c != tc.c {
+			t.Errorf("frame %d (%d, %d): "+errmsg, tc.frame, tc.x, tc.y, tc.c, c)
+		}
+	}
+}
+
+// shades returns two tiles in each of two shades of red.
+func shades() palette.Generator {
+	var tiles []palette.Tile
+	for _, c := range []color.RGBA{{255, 0, 0, 255}, {255, 0, 0, 255}, {204, 0, 0, 255}, {204, 0, 0, 255}} {
+		tiles = append(tiles, NewImageTile(fill(c, 4)))
+	}
+	return palette.GeneratorFunc(func(_ string, size int) (palette.Palette, error) {
+		return NewTilePalette(tiles, size), nil
+	})
+}
+
+func TestConverterDecodeAnimation(t *testing.T) {
+	// the second frame shifts slightly towards the darker shade
+	a := &Animation{
+		Frames: []image.Image{
+			fill(color.RGBA{255, 0, 0, 255}, 20),
+			fill(color.RGBA{210, 0, 0, 255}, 20),
+		},
+		Delays: []int{10, 10},
+	}
+
+	calls := 0
+	gen := shades()
+	counted := palette.GeneratorFunc(func(term string, size int) (palette.Palette, error) {
+		calls++
+		return gen.Palette(term, size)
+	})
+
+	render := func(coherence float64) Manifest {
+		d := NewConverter(a.Frames[0], "", WithWidth(2), WithHeight(2), WithSize(4), WithPaletteGenerator(counted), WithCoherence(coherence))
+		out, err := d.DecodeAnimation(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Frames) != 2 {
+			t.Fatalf(errmsg, 2, len(out.Frames))
+		}
+		if d.im != a.Frames[0] {
+			t.Errorf(errmsg, "source image restored", d.im)
+		}
+		return d.Manifest()
+	}
+
+	calls = 0
+	m := render(0)
+	if calls != 1 {
+		t.Errorf(errmsg, 1, calls)
+	}
+	// without coherence the last frame switches shade
+	if m[0].Color != "#cc0000" {
+		t.Errorf(errmsg, "#cc0000", m[0].Color)
+	}
+
+	m = render(20)
+	if m[0].Color != "#ff0000" {
+		t.Errorf(errmsg, "#ff0000", m[0].Color)
+	}
+}
+
+func TestAnimationEncoding(t *testing.T) {
+	a := &Animation{
+		Frames: []image.Image{gradient(8, 8), fill(color.RGBA{0, 255, 0, 255}, 8)},
+		Delays: []int{20, 40},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := a.EncodeGIF(buf); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 2 || g.Delay[1] != 40 {
+		t.Fatalf(errmsg, "2 frames", g)
+	}
+	if c := color.RGBAModel.Convert(g.Image[1].At(4, 4)); c != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf(errmsg, color.RGBA{0, 255, 0, 255}, c)
+	}
+
+	dir, err := ioutil.TempDir("", "frames")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := a.WriteFrames(dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadFrames(dir, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Frames) != 2 || loaded.Delays[0] != 7 {
+		t.Fatalf(errmsg, "2 frames", loaded)
+	}
+	if c := color.RGBAModel.Convert(loaded.Frames[1].At(0, 0)); c != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf(errmsg, color.RGBA{0, 255, 0, 255}, c)
+	}
+
+	if err := (&Animation{}).EncodeGIF(buf); err != (NoFrames{}) {
+		t.Errorf(errmsg, NoFrames{}, err)
+	}
+}
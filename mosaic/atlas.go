@@ -0,0 +1,225 @@
+package mosaic
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+const (
+	// PaletteVersion is the version of the on-disk atlas format.
+	PaletteVersion = 1
+	// PaletteManifest is the name of the index within an atlas
+	// directory.
+	PaletteManifest = "palette.json"
+
+	// DefaultAtlasColumns is the number of tiles packed per row and
+	// column of each atlas sheet.
+	DefaultAtlasColumns = 32
+)
+
+// Atlas packs equally sized tile images in to a small number of large
+// sheets, along with an index of where each tile lives and its color.
+type Atlas struct {
+	Size    int
+	Columns int
+	Sheets  []*image.RGBA
+	Entries []AtlasEntry
+}
+
+// AtlasEntry locates a single tile within an Atlas.
+type AtlasEntry struct {
+	Source string           `json:"source,omitempty"`
+	Color  palette.ColorKey `json:"color"`
+	Sheet  int              `json:"sheet"`
+	Index  int              `json:"index"`
+}
+
+// atlasIndex is the on-disk form of an Atlas.
+type atlasIndex struct {
+	Version int          `json:"version"`
+	Size    int          `json:"size"`
+	Columns int          `json:"columns"`
+	Sheets  []string     `json:"sheets"`
+	Tiles   []AtlasEntry `json:"tiles"`
+}
+
+func NewAtlas(size int) *Atlas {
+	return &Atlas{Size: size, Columns: DefaultAtlasColumns}
+}
+
+// Add scales tile to the atlas tile size and packs it in to the next
+// free cell, starting a new sheet when the current one is full.
+func (a *Atlas) Add(tile palette.Tile) error {
+	im, err := scaleTile(tile, a.Size)
+	if err != nil {
+		return err
+	}
+
+	perSheet := a.Columns * a.Columns
+	n := len(a.Entries)
+	sheet, index := n/perSheet, n%perSheet
+	full := image.Rect(0, 0, a.Columns*a.Size, a.Columns*a.Size)
+	switch {
+	case sheet == len(a.Sheets):
+		a.Sheets = append(a.Sheets, image.NewRGBA(full))
+	case a.Sheets[sheet].Bounds() != full:
+		// the trimmed final sheet of a loaded atlas
+		grown := image.NewRGBA(full)
+		draw.Draw(grown, a.Sheets[sheet].Bounds(), a.Sheets[sheet], image.ZP, draw.Src)
+		a.Sheets[sheet] = grown
+	}
+	draw.Draw(a.Sheets[sheet], a.cell(index), im, im.Bounds().Min, draw.Src)
+
+	entry := AtlasEntry{Color: palette.NewColorKey(tile), Sheet: sheet, Index: index}
+	if n, ok := tile.(palette.Named); ok {
+		entry.Source = n.Name()
+	}
+	a.Entries = append(a.Entries, entry)
+	return nil
+}
+
+// Tile returns the i'th tile of the atlas as a sub-image of its sheet.
+func (a *Atlas) Tile(i int) *ImageTile {
+	e := a.Entries[i]
+	source := e.Source
+	if source == "" {
+		source = fmt.Sprintf("sheet-%d.png#%d", e.Sheet, e.Index)
+	}
+	return &ImageTile{
+		Image:  a.Sheets[e.Sheet].SubImage(a.cell(e.Index)),
+		Color:  e.Color.RGBA64(),
+		Source: source,
+	}
+}
+
+// Save writes the atlas sheets and index to dir.
+func (a *Atlas) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	index := atlasIndex{
+		Version: PaletteVersion,
+		Size:    a.Size,
+		Columns: a.Columns,
+		Tiles:   a.Entries,
+	}
+
+	for i, sheet := range a.Sheets {
+		// trim unused rows from the final sheet
+		if i == len(a.Sheets)-1 {
+			used := len(a.Entries) - i*a.Columns*a.Columns
+			rows := (used + a.Columns - 1) / a.Columns
+			sheet = sheet.SubImage(image.Rect(0, 0, a.Columns*a.Size, rows*a.Size)).(*image.RGBA)
+		}
+
+		name := fmt.Sprintf("sheet-%d.png", i)
+		if err := writePNG(filepath.Join(dir, name), sheet); err != nil {
+			return err
+		}
+		index.Sheets = append(index.Sheets, name)
+	}
+
+	enc, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Create(filepath.Join(dir, PaletteManifest))
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	_, err = fi.Write(enc)
+	return err
+}
+
+// LoadAtlas reads an atlas written by Atlas.Save.
+func LoadAtlas(dir string) (*Atlas, error) {
+	fi, err := os.Open(filepath.Join(dir, PaletteManifest))
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	var index atlasIndex
+	if err := json.NewDecoder(fi).Decode(&index); err != nil {
+		return nil, err
+	}
+
+	if index.Version != PaletteVersion {
+		return nil, UnsupportedVersion{Version: index.Version}
+	}
+
+	if index.Size < 1 || index.Columns < 1 {
+		return nil, fmt.Errorf("invalid atlas of %d columns of %dpx tiles", index.Columns, index.Size)
+	}
+
+	a := &Atlas{Size: index.Size, Columns: index.Columns, Entries: index.Tiles}
+	for _, name := range index.Sheets {
+		im, err := readImage(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		// sheets are kept at their decoded size, so the index can't
+		// claim more memory than the sheets themselves take
+		b := im.Bounds()
+		if b.Dx()%a.Size != 0 || b.Dx()/a.Size != a.Columns || b.Dy() > b.Dx() {
+			return nil, fmt.Errorf("sheet %s of %dx%dpx doesn't hold %d columns of %dpx tiles", name, b.Dx(), b.Dy(), a.Columns, a.Size)
+		}
+		rgba := image.NewRGBA(image.Rectangle{Max: b.Size()})
+		draw.Draw(rgba, rgba.Bounds(), im, b.Min, draw.Src)
+		a.Sheets = append(a.Sheets, rgba)
+	}
+
+	for _, e := range a.Entries {
+		if e.Sheet < 0 || e.Sheet >= len(a.Sheets) {
+			return nil, fmt.Errorf("tile references missing sheet %d", e.Sheet)
+		}
+		if e.Index < 0 || e.Index >= a.Columns*a.Columns || !a.cell(e.Index).In(a.Sheets[e.Sheet].Bounds()) {
+			return nil, fmt.Errorf("tile references missing cell %d of sheet %d", e.Index, e.Sheet)
+		}
+	}
+	return a, nil
+}
+
+// cell returns the bounds of the i'th cell of a sheet.
+func (a *Atlas) cell(i int) image.Rectangle {
+	x, y := (i%a.Columns)*a.Size, (i/a.Columns)*a.Size
+	return image.Rect(x, y, x+a.Size, y+a.Size)
+}
+
+// AtlasPalette is a TilePalette whose tiles are served as sub-images
+// of an Atlas, so thousands of tiles need only a handful of decodes.
+type AtlasPalette struct {
+	*TilePalette
+	Atlas *Atlas
+}
+
+func NewAtlasPalette(a *Atlas) *AtlasPalette {
+	tiles := make([]palette.Tile, len(a.Entries))
+	for i := range a.Entries {
+		tiles[i] = a.Tile(i)
+	}
+	return &AtlasPalette{
+		TilePalette: NewTilePalette(tiles, a.Size),
+		Atlas:       a,
+	}
+}
+
+// errors
+
+type UnsupportedVersion struct {
+	Version int
+}
+
+func (u UnsupportedVersion) Error() string {
+	return fmt.Sprintf("unsupported palette version %d (expected %d)", u.Version, PaletteVersion)
+}
@@ -0,0 +1,101 @@
+package mosaic
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+func benchmarkDecode(b *testing.B, workers int, rows bool) {
+	im := gradient(256, 256)
+	for i := 0; i < b.N; i++ {
+		d := NewConverter(im, "",
+			WithWidth(32),
+			WithHeight(32),
+			WithSize(8),
+			WithWorkers(workers),
+			WithBuffers(workers*2, workers*2),
+			WithRowBatching(rows))
+		if _, err := d.Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode_Workers(b *testing.B) {
+	for _, n := range []int{1, 2, 4, 8} {
+		for _, rows := range []bool{false, true} {
+			b.Run(fmt.Sprintf("workers=%d/rows=%t", n, rows), func(b *testing.B) {
+				benchmarkDecode(b, n, rows)
+			})
+		}
+	}
+}
+
+func BenchmarkBounds(b *testing.B) {
+	d := NewConverter(gradient(256, 256), "", WithWidth(64), WithHeight(64))
+	for i := 0; i < b.N; i++ {
+		proc := make(chan []cell, 2)
+		go d.bounds(proc, d.im.Bounds())
+		for range proc {
+		}
+	}
+}
+
+func BenchmarkImageTile_ColorAt(b *testing.B) {
+	tile := NewImageTile(gradient(256, 256))
+	rect := image.Rect(0, 0, 32, 32)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tile.ColorAt(rect)
+	}
+}
+
+func BenchmarkTilePalette_Convert(b *testing.B) {
+	p, _ := NewUniformWebColorPalette("", 8)
+	key := palette.NewColorKey(color.RGBA{120, 40, 200, 255})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Convert(key)
+	}
+}
+
+func BenchmarkNewTilePalette(b *testing.B) {
+	tiles := tileSet(64, 8)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewTilePalette(tiles, 8)
+	}
+}
+
+func BenchmarkResize(b *testing.B) {
+	im := gradient(512, 384)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Resize(im, 100, 100); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkImageTile_ColorAtTypes(b *testing.B) {
+	src := gradient(256, 256)
+	bounds := src.Bounds()
+	nrgba := image.NewNRGBA(bounds)
+	gray := image.NewGray(bounds)
+	ycbcr := image.NewYCbCr(bounds, image.YCbCrSubsampleRatio420)
+
+	for _, im := range []image.Image{src, nrgba, gray, ycbcr, opaque{src}} {
+		tile := NewImageTile(im)
+		rect := image.Rect(0, 0, 32, 32)
+		b.Run(fmt.Sprintf("%T", im), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tile.ColorAt(rect)
+			}
+		})
+	}
+}
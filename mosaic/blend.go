@@ -0,0 +1,178 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// BlendMode selects how tiles are combined with the scaled source image
+// beneath them. The modes follow the W3C compositing specification,
+// with the source image as the backdrop and the tile as the source.
+type BlendMode int
+
+const (
+	// BlendNormal draws tiles over the source image.
+	BlendNormal BlendMode = iota
+	// BlendMultiply darkens the source image by the tile.
+	BlendMultiply
+	// BlendOverlay multiplies or screens depending on the source image,
+	// keeping its highlights and shadows.
+	BlendOverlay
+	// BlendSoftLight is a gentler overlay.
+	BlendSoftLight
+	// BlendLuminosity keeps the hue and saturation of the source image
+	// with the luminosity of the tile, so tiles show as texture.
+	BlendLuminosity
+	// BlendColor keeps the luminosity of the source image with the hue
+	// and saturation of the tile.
+	BlendColor
+)
+
+// BlendModes are the blend modes available by name.
+var BlendModes = map[string]BlendMode{
+	"normal":     BlendNormal,
+	"multiply":   BlendMultiply,
+	"overlay":    BlendOverlay,
+	"soft-light": BlendSoftLight,
+	"luminosity": BlendLuminosity,
+	"color":      BlendColor,
+}
+
+// cellAlpha returns the alpha the tile of s is drawn with: that of its
+// region, if set, or else the converter's. With adaptive alpha, it
+// falls from there for an exact match to the minimum at the configured
+// ΔE, so poorly matched cells let more of the source image through.
+func (d *Converter) cellAlpha(s source) uint8 {
+	alpha := d.alpha
+	if s.Region != nil && s.Region.Alpha > 0 {
+		alpha = s.Region.Alpha
+	}
+	if d.adaptiveDeltaE <= 0 || alpha <= d.adaptiveMin {
+		return alpha
+	}
+	t := math.Min(1, s.Placement.DeltaE/d.adaptiveDeltaE)
+	return uint8(float64(alpha) - t*float64(alpha-d.adaptiveMin) + 0.5)
+}
+
+// compose draws the tile of s on to dst according to the blend mode and
+// alpha of the converter. Cells left original have no tile, leaving the
+// source image beneath.
+func (d *Converter) compose(dst draw.Image, s source) {
+	if s.Image == nil {
+		return
+	}
+	alpha := d.cellAlpha(s)
+	if d.blend == BlendNormal {
+		mask := image.NewUniform(color.Alpha{A: alpha})
+		// tiles such as those of an atlas needn't start at the origin
+		draw.DrawMask(dst, s.Rect, s.Image, s.Image.Bounds().Min, mask, image.ZP, draw.Over)
+		return
+	}
+
+	a := float64(alpha) / 255
+	r := s.Rect.Intersect(dst.Bounds())
+	// tiles are aligned to the cell as they are by DrawMask
+	offset := s.Image.Bounds().Min.Sub(s.Rect.Min)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			tr, tg, tb, ta := s.Image.At(x+offset.X, y+offset.Y).RGBA()
+			if ta == 0 {
+				continue
+			}
+			br, bg, bb, ba := dst.At(x, y).RGBA()
+
+			backdrop := unpremultiply(br, bg, bb, ba)
+			tile := unpremultiply(tr, tg, tb, ta)
+			blended := blend(d.blend, backdrop, tile)
+
+			// mix, then premultiply by the backdrop's alpha again
+			k := a * float64(ta) / 0xffff
+			var out [3]uint16
+			for i := range out {
+				v := math.Max(0, math.Min(1, backdrop[i]*(1-k)+blended[i]*k))
+				out[i] = uint16(v*float64(ba) + 0.5)
+			}
+			dst.Set(x, y, color.RGBA64{R: out[0], G: out[1], B: out[2], A: uint16(ba)})
+		}
+	}
+}
+
+// unpremultiply returns the straight color components in [0, 1].
+func unpremultiply(r, g, b, a uint32) [3]float64 {
+	if a == 0 {
+		return [3]float64{}
+	}
+	fa := float64(a)
+	return [3]float64{float64(r) / fa, float64(g) / fa, float64(b) / fa}
+}
+
+// blend combines the backdrop b with source s under mode.
+func blend(mode BlendMode, b, s [3]float64) [3]float64 {
+	switch mode {
+	case BlendLuminosity:
+		return setLum(b, lum(s))
+	case BlendColor:
+		return setLum(s, lum(b))
+	}
+
+	var out [3]float64
+	for i := range out {
+		cb, cs := b[i], s[i]
+		switch mode {
+		case BlendMultiply:
+			out[i] = cb * cs
+		case BlendOverlay:
+			// hard light with the layers swapped
+			if cb <= 0.5 {
+				out[i] = cs * 2 * cb
+			} else {
+				out[i] = screen(cs, 2*cb-1)
+			}
+		case BlendSoftLight:
+			if cs <= 0.5 {
+				out[i] = cb - (1-2*cs)*cb*(1-cb)
+				continue
+			}
+			dcb := math.Sqrt(cb)
+			if cb <= 0.25 {
+				dcb = ((16*cb-12)*cb + 4) * cb
+			}
+			out[i] = cb + (2*cs-1)*(dcb-cb)
+		default:
+			out[i] = cs
+		}
+	}
+	return out
+}
+
+func screen(b, s float64) float64 {
+	return b + s - b*s
+}
+
+func lum(c [3]float64) float64 {
+	return 0.3*c[0] + 0.59*c[1] + 0.11*c[2]
+}
+
+// setLum shifts c to luminosity l, clipping back in to gamut while
+// preserving its luminosity.
+func setLum(c [3]float64, l float64) [3]float64 {
+	d := l - lum(c)
+	for i := range c {
+		c[i] += d
+	}
+
+	l = lum(c)
+	n := math.Min(c[0], math.Min(c[1], c[2]))
+	x := math.Max(c[0], math.Max(c[1], c[2]))
+	for i := range c {
+		if n < 0 {
+			c[i] = l + (c[i]-l)*l/(l-n)
+		}
+		if x > 1 {
+			c[i] = l + (c[i]-l)*(1-l)/(x-l)
+		}
+	}
+	return c
+}
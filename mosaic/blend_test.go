@@ -0,0 +1,117 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+func TestBlend(t *testing.T) {
+	var (
+		grey   = [3]float64{0.5, 0.5, 0.5}
+		dark   = [3]float64{0.2, 0.2, 0.2}
+		light  = [3]float64{0.8, 0.8, 0.8}
+		red    = [3]float64{1, 0, 0}
+		orange = [3]float64{1, 0.5, 0}
+	)
+
+	for _, tc := range []struct {
+		mode     BlendMode
+		b, s     [3]float64
+		expected [3]float64
+	}{
+		{BlendNormal, grey, red, red},
+		{BlendMultiply, grey, orange, [3]float64{0.5, 0.25, 0}},
+		// overlay keeps shadows dark and highlights light
+		{BlendOverlay, dark, grey, [3]float64{0.2, 0.2, 0.2}},
+		{BlendOverlay, light, grey, [3]float64{0.8, 0.8, 0.8}},
+		{BlendOverlay, dark, light, [3]float64{0.32, 0.32, 0.32}},
+		// soft light with mid grey changes nothing
+		{BlendSoftLight, dark, grey, dark},
+		{BlendSoftLight, grey, light, [3]float64{0.6243, 0.6243, 0.6243}},
+		// luminosity takes the lightness of the tile
+		{BlendLuminosity, red, grey, [3]float64{1, 0.2857, 0.2857}},
+		{BlendLuminosity, grey, red, [3]float64{0.3, 0.3, 0.3}},
+		// color takes the hue of the tile
+		{BlendColor, grey, red, [3]float64{1, 0.2857, 0.2857}},
+	} {
+		got := blend(tc.mode, tc.b, tc.s)
+		for i := range got {
+			if math.Abs(got[i]-tc.expected[i]) > 1e-3 {
+				t.Errorf("mode %d: "+errmsg, tc.mode, tc.expected, got)
+				break
+			}
+		}
+	}
+}
+
+func TestCellAlpha(t *testing.T) {
+	d := NewConverter(nil, "", WithAlpha(200))
+	if a := d.cellAlpha(source{Placement: Placement{DeltaE: 50}}); a != 200 {
+		t.Errorf(errmsg, 200, a)
+	}
+
+	WithAdaptiveAlpha(100, 20)(d)
+	for dE, expected := range map[float64]uint8{0: 200, 10: 150, 20: 100, 40: 100} {
+		if a := d.cellAlpha(source{Placement: Placement{DeltaE: dE}}); a != expected {
+			t.Errorf("ΔE %.0f: "+errmsg, dE, expected, a)
+		}
+	}
+}
+
+func TestCompose(t *testing.T) {
+	tile := NewImageTile(fill(color.RGBA{255, 128, 0, 255}, 4))
+	s := source{Image: tile, Rect: image.Rect(2, 2, 6, 6)}
+
+	dst := fill(color.RGBA{128, 128, 128, 255}, 8)
+	d := NewConverter(nil, "", WithBlendMode(BlendMultiply))
+	d.compose(dst, s)
+
+	if c := dst.At(3, 3); c != (color.RGBA{128, 64, 0, 255}) {
+		t.Errorf(errmsg, color.RGBA{128, 64, 0, 255}, c)
+	}
+	// outside of the cell is untouched
+	if c := dst.At(1, 1); c != (color.RGBA{128, 128, 128, 255}) {
+		t.Errorf(errmsg, color.RGBA{128, 128, 128, 255}, c)
+	}
+
+	// half alpha mixes the blend with the source image
+	dst = fill(color.RGBA{128, 128, 128, 255}, 8)
+	WithAlpha(128)(d)
+	d.compose(dst, s)
+	if c := dst.At(3, 3); palette.Distance(c, color.RGBA{128, 96, 64, 255}) > 1 {
+		t.Errorf(errmsg, color.RGBA{128, 96, 64, 255}, c)
+	}
+
+	// tiles needn't start at the origin, as those of an atlas don't
+	sheet := fill(color.RGBA{0, 0, 255, 255}, 8)
+	draw.Draw(sheet, image.Rect(4, 4, 8, 8), image.NewUniform(color.RGBA{255, 128, 0, 255}), image.ZP, draw.Src)
+	s.Image = sheet.SubImage(image.Rect(4, 4, 8, 8))
+
+	dst = fill(color.RGBA{128, 128, 128, 255}, 8)
+	WithAlpha(255)(d)
+	d.compose(dst, s)
+	for _, pt := range []image.Point{{2, 2}, {5, 5}} {
+		if c := dst.At(pt.X, pt.Y); c != (color.RGBA{128, 64, 0, 255}) {
+			t.Errorf(errmsg, color.RGBA{128, 64, 0, 255}, c)
+		}
+	}
+}
+
+func TestDecodeBlendMode(t *testing.T) {
+	im := fill(color.RGBA{255, 255, 255, 255}, 20)
+	d := NewConverter(im, "", WithWidth(2), WithHeight(2), WithSize(5), WithBlendMode(BlendLuminosity))
+	out, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// white tiles over white leave it unchanged
+	if c := color.RGBAModel.Convert(out.At(7, 7)); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf(errmsg, color.RGBA{255, 255, 255, 255}, c)
+	}
+}
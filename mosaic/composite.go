@@ -0,0 +1,184 @@
+package mosaic
+
+import (
+	"log"
+	"math"
+	"sort"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// Source is a single generator contributing tiles to a
+// CompositeGenerator. If Term is empty the term passed to the composite
+// is used. Weight scales how favourably its tiles are matched (a weight
+// of 2 halves the apparent color distance) and sources with a higher
+// Priority are consulted first.
+type Source struct {
+	palette.Generator
+	Term     string
+	Weight   float64
+	Priority int
+}
+
+// CompositeGenerator merges the palettes of several sources. A color is
+// matched against the highest priority sources first and only falls
+// through to lower priorities when no tile lies within Threshold.
+type CompositeGenerator struct {
+	Sources   []Source
+	Threshold float64
+}
+
+func NewCompositeGenerator(sources ...Source) *CompositeGenerator {
+	return &CompositeGenerator{
+		Sources:   sources,
+		Threshold: 32,
+	}
+}
+
+// WithFallback appends a lowest priority uniform web color source, so
+// every color is guaranteed a reasonably close tile.
+func (c *CompositeGenerator) WithFallback() *CompositeGenerator {
+	min := 0
+	for _, s := range c.Sources {
+		if s.Priority < min {
+			min = s.Priority
+		}
+	}
+	c.Sources = append(c.Sources, Source{
+		Generator: palette.GeneratorFunc(NewUniformWebColorPalette),
+		Weight:    1,
+		Priority:  min - 1,
+	})
+	return c
+}
+
+func (c *CompositeGenerator) Palette(term string, size int) (palette.Palette, error) {
+	comp := &CompositePalette{threshold: c.Threshold}
+
+	var err error
+	for _, s := range c.Sources {
+		t := s.Term
+		if t == "" {
+			t = term
+		}
+
+		var p palette.Palette
+		if p, err = s.Palette(t, size); err != nil {
+			log.Printf("[mosaic] Skipping palette source %q: %s\n", t, err)
+			continue
+		}
+
+		w := s.Weight
+		if w <= 0 {
+			w = 1
+		}
+		comp.add(p, w, s.Priority)
+	}
+
+	if len(comp.groups) == 0 {
+		if err == nil {
+			err = EmptyPalette{}
+		}
+		return nil, err
+	}
+	return comp, nil
+}
+
+// CompositePalette is the palette produced by a CompositeGenerator.
+type CompositePalette struct {
+	groups    []group
+	threshold float64
+}
+
+type group struct {
+	priority int
+	members  []member
+}
+
+type member struct {
+	palette.Palette
+	weight float64
+}
+
+func (c *CompositePalette) add(p palette.Palette, weight float64, priority int) {
+	m := member{Palette: p, weight: weight}
+	for i, g := range c.groups {
+		if g.priority == priority {
+			c.groups[i].members = append(g.members, m)
+			return
+		}
+	}
+	c.groups = append(c.groups, group{priority: priority, members: []member{m}})
+	sort.Sort(byPriority(c.groups))
+}
+
+// Convert returns the tile of the winning source for k. Only the winner
+// is asked to convert k, so sources which lose don't advance their
+// rotation through tiles of the same color.
+func (c *CompositePalette) Convert(k palette.ColorKey) palette.Tile {
+	m, tiles := c.nearest(k)
+	if len(tiles) == 0 {
+		return nil
+	}
+	if _, ok := m.Palette.(palette.Candidates); ok {
+		return m.Convert(k)
+	}
+	// candidates of other palettes came from Convert already
+	return tiles[0]
+}
+
+// Candidates applies the same priority and threshold rules as Convert,
+// but returns every candidate of the winning source rather than
+// rotating through them.
+func (c *CompositePalette) Candidates(k palette.ColorKey) []palette.Tile {
+	_, tiles := c.nearest(k)
+	return tiles
+}
+
+// nearest returns the member whose candidates for k are nearest once
+// weighted, consulting lower priorities only while none lie within the
+// threshold, along with those candidates.
+func (c *CompositePalette) nearest(k palette.ColorKey) (member, []palette.Tile) {
+	var (
+		target = k.Color()
+		best   member
+		tiles  []palette.Tile
+		min    = math.Inf(1)
+	)
+
+	for _, g := range c.groups {
+		for _, m := range g.members {
+			candidates := m.candidates(k)
+			if len(candidates) == 0 {
+				continue
+			}
+			if d := palette.Distance(target, candidates[0]) / m.weight; d < min {
+				best, tiles, min = m, candidates, d
+			}
+		}
+
+		// good enough, no need to fall through to lower priorities
+		if tiles != nil && min <= c.threshold {
+			break
+		}
+	}
+	return best, tiles
+}
+
+// candidates returns the tiles m holds for k, without advancing any
+// rotation when m can list them.
+func (m member) candidates(k palette.ColorKey) []palette.Tile {
+	if cp, ok := m.Palette.(palette.Candidates); ok {
+		return cp.Candidates(k)
+	}
+	if tile := m.Convert(k); tile != nil {
+		return []palette.Tile{tile}
+	}
+	return nil
+}
+
+type byPriority []group
+
+func (b byPriority) Len() int           { return len(b) }
+func (b byPriority) Less(i, j int) bool { return b[i].priority > b[j].priority }
+func (b byPriority) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
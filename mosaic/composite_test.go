@@ -0,0 +1,112 @@
+package mosaic
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+func TestCompositeGenerator_Palette(t *testing.T) {
+	red := NewImageTile(fill(color.RGBA{255, 0, 0, 255}, 4))
+	comp := NewCompositeGenerator(Source{
+		Generator: tilePalette([]palette.Tile{red}),
+		Priority:  1,
+	}).WithFallback()
+
+	p, err := comp.Palette("", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// red is served by the preferred source
+	if tile := p.Convert(palette.NewColorKey(color.RGBA{250, 0, 0, 255})); tile != palette.Tile(red) {
+		t.Errorf(errmsg, red, tile)
+	}
+
+	// blue falls through to the uniform web colors
+	blue := color.RGBA{0, 0, 255, 255}
+	if tile := p.Convert(palette.NewColorKey(blue)); palette.Distance(tile, blue) != 0 {
+		t.Errorf(errmsg, blue, tile)
+	}
+}
+
+func TestCompositeGenerator_Priority(t *testing.T) {
+	dark := NewImageTile(fill(color.RGBA{200, 0, 0, 255}, 4))
+	red := NewImageTile(fill(color.RGBA{255, 0, 0, 255}, 4))
+	comp := NewCompositeGenerator(
+		Source{Generator: tilePalette([]palette.Tile{dark}), Priority: 1},
+		Source{Generator: tilePalette([]palette.Tile{red})})
+
+	for _, test := range []struct {
+		threshold float64
+		expected  palette.Tile
+	}{
+		// dark lies beyond the threshold, so red is consulted
+		{32, red},
+		// dark is close enough to stop at the preferred source
+		{64, dark},
+	} {
+		comp.Threshold = test.threshold
+		p, err := comp.Palette("", 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		k := palette.NewColorKey(color.RGBA{255, 0, 0, 255})
+		if tile := p.Convert(k); tile != test.expected {
+			t.Errorf(errmsg, test.expected, tile)
+		}
+		if tiles := p.(*CompositePalette).Candidates(k); len(tiles) != 1 || tiles[0] != test.expected {
+			t.Errorf(errmsg, []palette.Tile{test.expected}, tiles)
+		}
+	}
+}
+
+func TestCompositeGenerator_Weight(t *testing.T) {
+	light := NewImageTile(fill(color.RGBA{255, 0, 0, 255}, 4))
+	dark := NewImageTile(fill(color.RGBA{235, 0, 0, 255}, 4))
+	comp := NewCompositeGenerator(
+		Source{Generator: tilePalette([]palette.Tile{light})},
+		Source{Generator: tilePalette([]palette.Tile{dark}), Weight: 4})
+
+	p, err := comp.Palette("", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both are equally far from the target, the weight breaks the tie
+	if tile := p.Convert(palette.NewColorKey(color.RGBA{245, 0, 0, 255})); tile != palette.Tile(dark) {
+		t.Errorf(errmsg, dark, tile)
+	}
+}
+
+func TestCompositePalette_Rotation(t *testing.T) {
+	tiles := func(c color.RGBA) []palette.Tile {
+		return []palette.Tile{NewImageTile(fill(c, 4)), NewImageTile(fill(c, 4))}
+	}
+	red, dark := tiles(color.RGBA{255, 0, 0, 255}), tiles(color.RGBA{200, 0, 0, 255})
+	p, err := NewCompositeGenerator(
+		Source{Generator: tilePalette(red)},
+		Source{Generator: tilePalette(dark)}).Palette("", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tile := p.Convert(palette.NewColorKey(color.RGBA{255, 0, 0, 255})); tile != red[0] {
+		t.Errorf(errmsg, red[0], tile)
+	}
+	// losing the first lookup leaves the dark source's rotation alone
+	if tile := p.Convert(palette.NewColorKey(color.RGBA{200, 0, 0, 255})); tile != dark[0] {
+		t.Errorf(errmsg, dark[0], tile)
+	}
+	if tile := p.Convert(palette.NewColorKey(color.RGBA{255, 0, 0, 255})); tile != red[1] {
+		t.Errorf(errmsg, red[1], tile)
+	}
+}
+
+func TestCompositeGenerator_Empty(t *testing.T) {
+	if _, err := NewCompositeGenerator().Palette("", 4); err != (EmptyPalette{}) {
+		t.Errorf(errmsg, EmptyPalette{}, err)
+	}
+}
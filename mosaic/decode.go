@@ -0,0 +1,139 @@
+package mosaic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"io/ioutil"
+)
+
+// DecodeImage decodes an image from r in the same way as image.Decode,
+// but honours the EXIF orientation tag of JPEG sources so that the
+// returned image is the right way up.
+func DecodeImage(r io.Reader) (image.Image, string, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	im, format, err := image.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return nil, format, err
+	}
+
+	if format == "jpeg" {
+		im = orient(im, exifOrientation(buf))
+	}
+
+	return im, format, nil
+}
+
+// exifOrientation scans the segments of a JPEG stream for an APP1 EXIF
+// block and returns the value of its orientation tag. It returns 1
+// (no transformation) when the tag is missing or cannot be parsed.
+func exifOrientation(b []byte) int {
+	if len(b) < 4 || b[0] != 0xff || b[1] != 0xd8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xff {
+			return 1
+		}
+		marker := b[i+1]
+		// start of scan, no more metadata segments to come
+		if marker == 0xda {
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(b[i+2 : i+4]))
+		if size < 2 || i+2+size > len(b) {
+			return 1
+		}
+		seg := b[i+4 : i+2+size]
+		if marker == 0xe1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag (0x0112) from the first
+// IFD of a TIFF structure.
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	off := int(order.Uint32(t[4:8]))
+	if off+2 > len(t) {
+		return 1
+	}
+	n := int(order.Uint16(t[off : off+2]))
+	for i := 0; i < n; i++ {
+		e := off + 2 + i*12
+		if e+12 > len(t) {
+			return 1
+		}
+		if order.Uint16(t[e:e+2]) == 0x0112 {
+			o := int(order.Uint16(t[e+8 : e+10]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+	}
+	return 1
+}
+
+// orient applies the rotation and/or flip described by the EXIF
+// orientation value o to im.
+func orient(im image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return im
+	}
+
+	bounds := im.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	// orientations 5 to 8 swap the axes
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch o {
+			case 2: // flip horizontal
+				sx, sy = w-1-x, y
+			case 3: // rotate 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip vertical
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 270 clockwise
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, im.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return dst
+}
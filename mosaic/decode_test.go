@@ -0,0 +1,93 @@
+package mosaic
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// withOrientation splices an EXIF APP1 segment carrying orientation o
+// in to the JPEG encoding b.
+func withOrientation(b []byte, o byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8,
+		0, 1,
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, o, 0, 0,
+		0, 0, 0, 0,
+	}
+	app := append([]byte("Exif\x00\x00"), tiff...)
+	seg := append([]byte{0xff, 0xe1, 0, byte(len(app) + 2)}, app...)
+
+	out := append([]byte{}, b[:2]...)
+	out = append(out, seg...)
+	return append(out, b[2:]...)
+}
+
+func TestDecodeImage_Orientation(t *testing.T) {
+	// 40x20 image, red in its top left quadrant and blue elsewhere, so
+	// every flip and rotation places the red differently
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{0, 0, 255, 255}
+			if x < 20 && y < 10 {
+				c = color.RGBA{255, 0, 0, 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	wide, tall := image.Pt(40, 20), image.Pt(20, 40)
+	for _, test := range []struct {
+		orientation byte
+		size        image.Point
+		// quadrant which should be red after orientation, with the
+		// others blue
+		red int
+	}{
+		{1, wide, topLeft},
+		{2, wide, topRight},
+		{3, wide, bottomRight},
+		{4, wide, bottomLeft},
+		{5, tall, topLeft},
+		{6, tall, topRight},
+		{7, tall, bottomRight},
+		{8, tall, bottomLeft},
+	} {
+		im, format, err := DecodeImage(bytes.NewReader(withOrientation(buf.Bytes(), test.orientation)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != "jpeg" {
+			t.Errorf(errmsg, "jpeg", format)
+		}
+		if size := im.Bounds().Size(); size != test.size {
+			t.Errorf("[%d] "+errmsg, test.orientation, test.size, size)
+			continue
+		}
+
+		for q := topLeft; q <= bottomRight; q++ {
+			// sample the centre of each quadrant
+			x, y := test.size.X/4+q%2*test.size.X/2, test.size.Y/4+q/2*test.size.Y/2
+			r, _, b, _ := im.At(x, y).RGBA()
+			if red := r > b; red != (q == test.red) {
+				t.Errorf("[%d] quadrant %d: "+errmsg, test.orientation, q, q == test.red, red)
+			}
+		}
+	}
+}
+
+// quadrants of an image, in reading order
+const (
+	topLeft = iota
+	topRight
+	bottomLeft
+	bottomRight
+)
@@ -0,0 +1,170 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"sort"
+	"sync"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// DitherKernel selects how the difference between a cell's color and
+// the color of its tile is diffused to neighbouring cells.
+type DitherKernel int
+
+const (
+	DitherNone DitherKernel = iota
+	// DitherFloydSteinberg diffuses all of the error across the next
+	// cell and the three below.
+	DitherFloydSteinberg
+	// DitherAtkinson diffuses three quarters of the error across six
+	// neighbours, giving higher contrast results.
+	DitherAtkinson
+)
+
+// diffusion is a single weighted neighbour of a dither kernel.
+type diffusion struct {
+	dx, dy int
+	weight float64
+}
+
+var kernels = map[DitherKernel][]diffusion{
+	DitherFloydSteinberg: {
+		{1, 0, 7.0 / 16},
+		{-1, 1, 3.0 / 16},
+		{0, 1, 5.0 / 16},
+		{1, 1, 1.0 / 16},
+	},
+	DitherAtkinson: {
+		{1, 0, 1.0 / 8},
+		{2, 0, 1.0 / 8},
+		{-1, 1, 1.0 / 8},
+		{0, 1, 1.0 / 8},
+		{1, 1, 1.0 / 8},
+		{0, 2, 1.0 / 8},
+	},
+}
+
+// sample is a cell along with the color sampled from it.
+type sample struct {
+	cell
+	color color.Color
+}
+
+// dithered matches cells to tiles in scan order, adding the error
+// diffused from previously matched cells to each cell's color before
+// matching. Sampling cell colors still happens across workers, but
+// matching is necessarily sequential. Cells left original or
+// subdivided by a mask neither take nor diffuse error.
+func (d *Converter) dithered(proc <-chan []cell, comp chan<- source, palettes map[int]palette.Palette, imtile *ImageTile) error {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		samples []sample
+	)
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			for batch := range proc {
+				for _, c := range batch {
+					s := sample{cell: c, color: d.cellColor(imtile, c.Rect)}
+					mu.Lock()
+					samples = append(samples, s)
+					mu.Unlock()
+				}
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	sort.Sort(byIndex(samples))
+
+	var rows, cols int
+	for _, s := range samples {
+		if s.Row >= rows {
+			rows = s.Row + 1
+		}
+		if s.Col >= cols {
+			cols = s.Col + 1
+		}
+	}
+
+	kernel := kernels[d.dither]
+	errs := make([][3]float64, rows*cols)
+	for _, s := range samples {
+		reg := d.region(s.Rect)
+		if reg != nil && (reg.Original || subdivisions(reg) > 1) {
+			srcs, err := d.render(palettes, imtile, s.cell, s.color)
+			if err != nil {
+				return err
+			}
+			for _, src := range srcs {
+				comp <- src
+			}
+			continue
+		}
+
+		r, g, b, _ := s.color.RGBA()
+		e := errs[s.Row*cols+s.Col]
+		target := [3]float64{float64(r) + e[0], float64(g) + e[1], float64(b) + e[2]}
+		tc := color.RGBA64{clamp16(target[0]), clamp16(target[1]), clamp16(target[2]), 0xffff}
+
+		im, err := d.match(palettes[d.size], tc, s.Index)
+		if err != nil {
+			return err
+		}
+
+		if c := imageColor(im); c != nil {
+			mr, mg, mb, _ := c.RGBA()
+			diff := [3]float64{target[0] - float64(mr), target[1] - float64(mg), target[2] - float64(mb)}
+			for _, k := range kernel {
+				row, col := s.Row+k.dy, s.Col+k.dx
+				if row < 0 || row >= rows || col < 0 || col >= cols {
+					continue
+				}
+				for i := range diff {
+					errs[row*cols+col][i] += diff[i] * k.weight
+				}
+			}
+		}
+
+		comp <- source{
+			Image:     im,
+			Rect:      s.Dst,
+			Placement: newPlacement(s.Rect, s.Dst, s.color, im),
+			Cell:      s.cell,
+			Region:    reg,
+		}
+	}
+	return nil
+}
+
+// imageColor returns the color a tile image was matched on, or nil if
+// it is unknown.
+func imageColor(im image.Image) color.Color {
+	switch t := im.(type) {
+	case palette.Tile:
+		return t
+	case *image.Uniform:
+		return t.C
+	}
+	return nil
+}
+
+func clamp16(v float64) uint16 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 0xffff:
+		return 0xffff
+	}
+	return uint16(v)
+}
+
+type byIndex []sample
+
+func (b byIndex) Len() int           { return len(b) }
+func (b byIndex) Less(i, j int) bool { return b[i].Index < b[j].Index }
+func (b byIndex) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,74 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+func TestConverter_DecodeDithered(t *testing.T) {
+	black := &UniformTile{Uniform: image.NewUniform(color.RGBA{0, 0, 0, 255})}
+	white := &UniformTile{Uniform: image.NewUniform(color.RGBA{255, 255, 255, 255})}
+	gen := tilePalette([]palette.Tile{black, white})
+	gray := fill(color.RGBA{102, 102, 102, 255}, 40)
+
+	for _, test := range []struct {
+		kernel   DitherKernel
+		min, max int
+	}{
+		// without dithering every cell gets the nearest tile, black
+		{DitherNone, 0, 0},
+		// 40% gray should diffuse to roughly 40% white tiles
+		{DitherFloydSteinberg, 30, 50},
+		{DitherAtkinson, 20, 50},
+	} {
+		d := NewConverter(gray, "",
+			WithWidth(10),
+			WithHeight(10),
+			WithSize(2),
+			WithDithering(test.kernel),
+			WithPaletteGenerator(gen))
+		if _, err := d.Decode(); err != nil {
+			t.Fatal(err)
+		}
+
+		var whites int
+		for _, p := range d.Manifest() {
+			if p.Color == "#ffffff" {
+				whites++
+			}
+		}
+		if whites < test.min || whites > test.max {
+			t.Errorf("kernel %d: "+errmsg, test.kernel, [2]int{test.min, test.max}, whites)
+		}
+	}
+}
+
+func TestConverter_DecodeDitheredWebSafe(t *testing.T) {
+	placements := func(k DitherKernel) Manifest {
+		d := NewConverter(gradient(64, 64), "",
+			WithWidth(16),
+			WithHeight(16),
+			WithSize(2),
+			WithDithering(k))
+		if _, err := d.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		return d.Manifest()
+	}
+
+	plain := placements(DitherNone)
+	for _, k := range []DitherKernel{DitherFloydSteinberg, DitherAtkinson} {
+		var changed int
+		for i, p := range placements(k) {
+			if p.Color != plain[i].Color {
+				changed++
+			}
+		}
+		if changed == 0 {
+			t.Errorf("kernel %d: Expected dithering to change placements\n", k)
+		}
+	}
+}
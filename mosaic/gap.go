@@ -0,0 +1,76 @@
+package mosaic
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// GapPolicy decides what a Converter does with a cell whose color has
+// no tile in the palette, or none within the configured max distance.
+type GapPolicy int
+
+const (
+	// GapNearest uses the nearest tile the palette has to offer,
+	// regardless of how far away it is.
+	GapNearest GapPolicy = iota
+	// GapFallbackColor fills the cell with the configured fallback
+	// color, or with the cell's own color if none is set.
+	GapFallbackColor
+	// GapError aborts the conversion with an UnmatchedColor error.
+	GapError
+)
+
+// match returns the tile image used to render the cell at index of
+// color c, reusing the tile from the previous frame of an animation
+// when coherent.
+func (d *Converter) match(p palette.Palette, c color.Color, index int) (image.Image, error) {
+	if im := d.history.get(index, c); im != nil {
+		return im, nil
+	}
+
+	im, err := d.gap(p, c, index)
+	if err == nil {
+		d.history.put(index, c, im)
+	}
+	return im, err
+}
+
+// gap returns the tile for color c, applying the gap policy when the
+// palette has no tile close enough.
+func (d *Converter) gap(p palette.Palette, c color.Color, index int) (image.Image, error) {
+	tile := d.pick(p, c, index)
+	if tile != nil && palette.Distance(c, tile) <= d.maxDistance {
+		return tile, nil
+	}
+
+	switch d.gaps {
+	case GapFallbackColor:
+		if d.fallback != nil {
+			c = d.fallback
+		}
+		return image.NewUniform(c), nil
+	case GapNearest:
+		if tile != nil {
+			return tile, nil
+		}
+	}
+	return nil, UnmatchedColor{Color: c}
+}
+
+// noMaxDistance accepts every tile returned by a palette.
+var noMaxDistance = math.Inf(1)
+
+// errors
+
+type UnmatchedColor struct {
+	Color color.Color
+}
+
+func (u UnmatchedColor) Error() string {
+	r, g, b, a := u.Color.RGBA()
+	return fmt.Sprintf("no tile found for color rgba(%d, %d, %d, %d)", r>>8, g>>8, b>>8, a>>8)
+}
@@ -0,0 +1,110 @@
+package mosaic
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+var (
+	errmsg string = "Expected %v, Got %v\n"
+	update        = flag.Bool("update", false, "update golden images in testdata")
+)
+
+func init() {
+	log.SetOutput(ioutil.Discard)
+}
+
+// gradient returns a w by h image with colors varying along both axes.
+func gradient(w, h int) image.Image {
+	im := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			im.Set(x, y, color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), uint8((x + y) * 127 / (w + h)), 255})
+		}
+	}
+	return im
+}
+
+// fill returns a size by size image of a single color.
+func fill(c color.Color, size int) *image.RGBA {
+	im := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(im, im.Bounds(), image.NewUniform(c), image.ZP, draw.Src)
+	return im
+}
+
+// tileSet returns n image tiles of size px, spread evenly across a
+// red to blue ramp with a checked pattern so they are distinguishable
+// from flat color.
+func tileSet(n, size int) []palette.Tile {
+	tiles := make([]palette.Tile, 0, n)
+	for i := 0; i < n; i++ {
+		v := uint8(i * 255 / n)
+		im := fill(color.RGBA{v, 64, 255 - v, 255}, size)
+		for y := 0; y < size; y += 2 {
+			im.Set(y, y, color.White)
+		}
+		tile := NewImageTile(im)
+		tile.Source = filepath.Join("tiles", string('a'+rune(i))+".png")
+		tiles = append(tiles, tile)
+	}
+	return tiles
+}
+
+// golden compares im with testdata/name.png using a perceptual
+// tolerance: the mean ΔE over all pixels must stay below 1 and no
+// single pixel may differ by more than 10. Run the tests with -update
+// to regenerate the golden images.
+func golden(t *testing.T, name string, im image.Image) {
+	path := filepath.Join("testdata", name+".png")
+	if *update {
+		fi, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fi.Close()
+		if err := png.Encode(fi, im); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	fi, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fi.Close()
+
+	want, err := png.Decode(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wb, gb := want.Bounds(), im.Bounds()
+	if wb.Size() != gb.Size() {
+		t.Fatalf(errmsg, wb.Size(), gb.Size())
+	}
+
+	var total, max float64
+	for y := 0; y < wb.Dy(); y++ {
+		for x := 0; x < wb.Dx(); x++ {
+			d := palette.DeltaE(want.At(wb.Min.X+x, wb.Min.Y+y), im.At(gb.Min.X+x, gb.Min.Y+y))
+			total += d
+			max = math.Max(max, d)
+		}
+	}
+
+	if mean := total / float64(wb.Dx()*wb.Dy()); mean > 1 || max > 10 {
+		t.Errorf("%s differs from golden image: mean ΔE %.2f, max ΔE %.2f", name, mean, max)
+	}
+}
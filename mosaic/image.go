@@ -4,8 +4,6 @@ import (
 	"image"
 	"image/color"
 	plt "image/color/palette"
-
-	"github.com/GeorgeMac/gomosaic/mosaic/palette"
 )
 
 // type convert palette.WebSafe in to color.Palette type locally
@@ -14,6 +12,8 @@ var WebSafe color.Palette = color.Palette(plt.WebSafe)
 type ImageTile struct {
 	image.Image
 	Color color.Color
+	// Source identifies where the tile image came from, e.g. a path
+	Source string
 }
 
 func NewImageTile(i image.Image) *ImageTile {
@@ -31,37 +31,27 @@ func NewImageTile(i image.Image) *ImageTile {
 	return tile
 }
 
+func (t *ImageTile) Name() string {
+	return t.Source
+}
+
 func (t *ImageTile) RGBA() (r, g, b, a uint32) {
 	return t.Color.RGBA()
 }
 
+// ColorAt returns the most common web safe color within r.
 func (t *ImageTile) ColorAt(r image.Rectangle) color.Color {
-	bins := map[palette.ColorKey]int{}
-
-	for y := r.Min.Y; y < r.Max.Y; y++ {
-		for x := r.Min.X; x < r.Max.X; x++ {
-			key := palette.NewColorKey(WebSafe.Convert(t.Image.At(x, y)))
-			if v, ok := bins[key]; ok {
-				bins[key] = v + 1
-				continue
-			}
-			bins[key] = 1
-		}
-	}
-
-	var (
-		c   color.Color
-		max int
-	)
-
-	for k, v := range bins {
-		if c == nil || v > max {
-			c = k.Color()
-			max = v
-		}
-	}
+	var b bins
+	b.count(t.Image, r)
+	return b.max()
+}
 
-	return c
+// MeanAt returns the mean color within r, for matching against palettes
+// whose colors aren't web safe.
+func (t *ImageTile) MeanAt(r image.Rectangle) color.Color {
+	var b bins
+	b.count(t.Image, r)
+	return b.mean()
 }
 
 // Uniform tile implements Tile interface
@@ -74,3 +64,7 @@ type UniformTile struct {
 func (u *UniformTile) ColorAt(_ image.Rectangle) color.Color {
 	return u.C
 }
+
+func (u *UniformTile) Name() string {
+	return Hex(u.C)
+}
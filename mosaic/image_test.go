@@ -0,0 +1,93 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestImageTile_ColorAt(t *testing.T) {
+	im := fill(color.RGBA{255, 0, 0, 255}, 10)
+	// a minority of blue pixels
+	for x := 0; x < 10; x++ {
+		im.Set(x, 0, color.RGBA{0, 0, 255, 255})
+	}
+	tile := NewImageTile(im)
+
+	red := color.RGBA{255, 0, 0, 255}
+	if c := color.RGBAModel.Convert(tile.ColorAt(im.Bounds())); c != red {
+		t.Errorf(errmsg, red, c)
+	}
+
+	blue := color.RGBA{0, 0, 255, 255}
+	if c := color.RGBAModel.Convert(tile.ColorAt(image.Rect(0, 0, 10, 1))); c != blue {
+		t.Errorf(errmsg, blue, c)
+	}
+}
+
+func TestImageTile_ColorAtTies(t *testing.T) {
+	im := fill(color.RGBA{255, 0, 0, 255}, 2)
+	im.Set(0, 0, color.RGBA{0, 0, 255, 255})
+	im.Set(1, 0, color.RGBA{0, 0, 255, 255})
+	tile := NewImageTile(im)
+
+	// an even split always resolves to the same color
+	expected := tile.ColorAt(im.Bounds())
+	for i := 0; i < 20; i++ {
+		if c := tile.ColorAt(im.Bounds()); c != expected {
+			t.Fatalf(errmsg, expected, c)
+		}
+	}
+}
+
+func TestUniformTile_ColorAt(t *testing.T) {
+	tile := &UniformTile{Uniform: image.NewUniform(color.White)}
+	if c := tile.ColorAt(image.Rect(0, 0, 5, 5)); c != color.White {
+		t.Errorf(errmsg, color.White, c)
+	}
+}
+
+// opaque hides the concrete type of an image, forcing ColorAt through
+// the generic image.Image path.
+type opaque struct {
+	image.Image
+}
+
+func TestImageTile_ColorAtFastPaths(t *testing.T) {
+	src := gradient(64, 48)
+	bounds := src.Bounds()
+
+	nrgba := image.NewNRGBA(bounds)
+	gray := image.NewGray(bounds)
+	ycbcr := image.NewYCbCr(bounds, image.YCbCrSubsampleRatio420)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := src.At(x, y)
+			nrgba.Set(x, y, color.NRGBA{uint8(x * 4), uint8(y * 5), 90, uint8(128 + x)})
+			gray.Set(x, y, c)
+			yc := color.YCbCrModel.Convert(c).(color.YCbCr)
+			ycbcr.Y[ycbcr.YOffset(x, y)] = yc.Y
+			ycbcr.Cb[ycbcr.COffset(x, y)] = yc.Cb
+			ycbcr.Cr[ycbcr.COffset(x, y)] = yc.Cr
+		}
+	}
+
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 64, 48),
+		image.Rect(3, 7, 19, 23),
+		image.Rect(50, 40, 70, 60),
+		image.Rect(5, 5, 5, 5),
+	}
+	for _, im := range []image.Image{src, nrgba, gray, ycbcr} {
+		fast, slow := NewImageTile(im), NewImageTile(opaque{im})
+		for _, r := range rects {
+			if f, s := fast.ColorAt(r), slow.ColorAt(r); f != s {
+				t.Errorf("%T %v: "+errmsg, im, r, s, f)
+			}
+			// means expose any difference in precision binning hides
+			if f, s := fast.MeanAt(r), slow.MeanAt(r); f != s {
+				t.Errorf("%T %v mean: "+errmsg, im, r, s, f)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package mosaic
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// Placement records the tile drawn in to a single cell of a mosaic.
+type Placement struct {
+	// Cell is the region of the source image the cell was sampled from
+	Cell image.Rectangle `json:"cell"`
+	// Rect is the region of the mosaic the tile was drawn in to
+	Rect image.Rectangle `json:"rect"`
+	// Tile identifies the tile, see palette.Named, falling back to its
+	// color for tiles without a name
+	Tile   string  `json:"tile"`
+	Target string  `json:"target"`
+	Color  string  `json:"color"`
+	DeltaE float64 `json:"delta_e"`
+}
+
+func newPlacement(cell, rect image.Rectangle, target color.Color, im image.Image) Placement {
+	p := Placement{
+		Cell:   cell,
+		Rect:   rect,
+		Target: Hex(target),
+	}
+
+	c := imageColor(im)
+	if c != nil {
+		p.Color = Hex(c)
+		p.DeltaE = palette.DeltaE(target, c)
+	}
+
+	// tiles without a name, such as image tiles of unknown source, are
+	// identified by their color
+	if n, ok := im.(palette.Named); ok {
+		p.Tile = n.Name()
+	}
+	if p.Tile == "" && c != nil {
+		p.Tile = p.Color
+	}
+	return p
+}
+
+// Manifest is the list of placements making up a mosaic, ordered by
+// row and then column.
+type Manifest []Placement
+
+func (m Manifest) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(m)
+}
+
+func (m Manifest) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{
+		"cell_min_x", "cell_min_y", "cell_max_x", "cell_max_y",
+		"min_x", "min_y", "max_x", "max_y",
+		"tile", "target", "color", "delta_e",
+	}); err != nil {
+		return err
+	}
+
+	for _, p := range m {
+		row := []string{}
+		for _, v := range []int{
+			p.Cell.Min.X, p.Cell.Min.Y, p.Cell.Max.X, p.Cell.Max.Y,
+			p.Rect.Min.X, p.Rect.Min.Y, p.Rect.Max.X, p.Rect.Max.Y,
+		} {
+			row = append(row, strconv.Itoa(v))
+		}
+		row = append(row, p.Tile, p.Target, p.Color, strconv.FormatFloat(p.DeltaE, 'f', 4, 64))
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// At returns the placement drawn at p in the mosaic.
+func (m Manifest) At(p image.Point) (Placement, bool) {
+	for _, pl := range m {
+		if p.In(pl.Rect) {
+			return pl, true
+		}
+	}
+	return Placement{}, false
+}
+
+func (m Manifest) Len() int      { return len(m) }
+func (m Manifest) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m Manifest) Less(i, j int) bool {
+	a, b := m[i].Rect.Min, m[j].Rect.Min
+	if a.Y == b.Y {
+		return a.X < b.X
+	}
+	return a.Y < b.Y
+}
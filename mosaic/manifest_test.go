@@ -0,0 +1,99 @@
+package mosaic
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// manifested decodes a two cell mosaic, red on the left and blue on the
+// right, from a red tile with a source and a blue tile without one.
+func manifested(t *testing.T) Manifest {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	named := NewImageTile(fill(red, 4))
+	named.Source = "tiles/red.png"
+	unnamed := NewImageTile(fill(blue, 4))
+
+	im := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			c := red
+			if x >= 10 {
+				c = blue
+			}
+			im.Set(x, y, c)
+		}
+	}
+
+	d := NewConverter(im, "",
+		WithWidth(2),
+		WithHeight(1),
+		WithSize(4),
+		WithPaletteGenerator(tilePalette([]palette.Tile{named, unnamed})))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	return d.Manifest()
+}
+
+func TestManifest_WriteJSON(t *testing.T) {
+	m := manifested(t)
+
+	var buf bytes.Buffer
+	if err := m.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Manifest
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := Manifest{
+		{Cell: image.Rect(0, 0, 10, 10), Rect: image.Rect(0, 0, 4, 4), Tile: "tiles/red.png", Target: "#ff0000", Color: "#ff0000"},
+		{Cell: image.Rect(10, 0, 20, 10), Rect: image.Rect(4, 0, 8, 4), Tile: "#0000ff", Target: "#0000ff", Color: "#0000ff"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf(errmsg, expected, got)
+	}
+}
+
+func TestManifest_WriteCSV(t *testing.T) {
+	m := manifested(t)
+
+	var buf bytes.Buffer
+	if err := m.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"cell_min_x", "cell_min_y", "cell_max_x", "cell_max_y", "min_x", "min_y", "max_x", "max_y", "tile", "target", "color", "delta_e"},
+		{"0", "0", "10", "10", "0", "0", "4", "4", "tiles/red.png", "#ff0000", "#ff0000", "0.0000"},
+		{"10", "0", "20", "10", "4", "0", "8", "4", "#0000ff", "#0000ff", "#0000ff", "0.0000"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf(errmsg, expected, rows)
+	}
+}
+
+func TestManifestAt(t *testing.T) {
+	m := Manifest{
+		{Rect: image.Rect(0, 0, 10, 10), Tile: "a"},
+		{Rect: image.Rect(10, 0, 20, 10), Tile: "b"},
+	}
+	if p, ok := m.At(image.Pt(15, 5)); !ok || p.Tile != "b" {
+		t.Errorf(errmsg, "b", p.Tile)
+	}
+	if _, ok := m.At(image.Pt(25, 5)); ok {
+		t.Errorf(errmsg, false, ok)
+	}
+}
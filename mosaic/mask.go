@@ -0,0 +1,219 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// Region overrides how cells are rendered where the mask given to
+// WithMask falls between Min and Max (inclusive), averaged over the
+// cell.
+type Region struct {
+	Min, Max uint8
+	// Subdivide splits each cell in to Subdivide by Subdivide smaller
+	// tiles, for more detail
+	Subdivide int
+	// Alpha replaces the converter's alpha for tiles in the region when
+	// non-zero
+	Alpha uint8
+	// Original leaves the region as the source image, without tiles
+	Original bool
+}
+
+// maxSubdivide bounds Subdivide, keeping sub-cell indices unique.
+const maxSubdivide = 32
+
+// region returns the region the mask places rect of the source image
+// in, or nil when there is none.
+func (d *Converter) region(rect image.Rectangle) *Region {
+	if d.mask == nil || len(d.regions) == 0 {
+		return nil
+	}
+
+	// map rect on to the mask, which may differ in size from the source
+	src, mb := d.im.Bounds(), d.mask.Bounds()
+	sx, sy := float64(mb.Dx())/float64(src.Dx()), float64(mb.Dy())/float64(src.Dy())
+	r := image.Rect(
+		mb.Min.X+int(float64(rect.Min.X-src.Min.X)*sx),
+		mb.Min.Y+int(float64(rect.Min.Y-src.Min.Y)*sy),
+		mb.Min.X+int(math.Ceil(float64(rect.Max.X-src.Min.X)*sx)),
+		mb.Min.Y+int(math.Ceil(float64(rect.Max.Y-src.Min.Y)*sy)),
+	).Intersect(mb)
+	if r.Empty() {
+		return nil
+	}
+
+	var sum, n int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sum += int(color.GrayModel.Convert(d.mask.At(x, y)).(color.Gray).Y)
+			n++
+		}
+	}
+	v := uint8((sum + n/2) / n)
+
+	for i, reg := range d.regions {
+		if v >= reg.Min && v <= reg.Max {
+			return &d.regions[i]
+		}
+	}
+	return nil
+}
+
+// palettes generates a palette at the reduced tile size of every
+// subdivided region, keyed by tile size.
+func (d *Converter) palettes(p palette.Palette) (map[int]palette.Palette, error) {
+	palettes := map[int]palette.Palette{d.size: p}
+	for _, reg := range d.regions {
+		size := d.subSize(&reg)
+		if _, ok := palettes[size]; ok {
+			continue
+		}
+		sp, err := d.generator.Palette(d.term, size)
+		if err != nil {
+			return nil, err
+		}
+		palettes[size] = sp
+	}
+	return palettes, nil
+}
+
+// subdivisions returns how many ways reg splits each side of a cell.
+func subdivisions(reg *Region) int {
+	if reg == nil || reg.Original || reg.Subdivide < 2 {
+		return 1
+	}
+	if reg.Subdivide > maxSubdivide {
+		return maxSubdivide
+	}
+	return reg.Subdivide
+}
+
+func (d *Converter) subSize(reg *Region) int {
+	size := d.size / subdivisions(reg)
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// subdivided matches each sub-cell of a cell in a subdivided region to
+// a tile from the palette of the reduced tile size.
+func (d *Converter) subdivided(palettes map[int]palette.Palette, imtile *ImageTile, cl cell, reg *Region) ([]source, error) {
+	n := subdivisions(reg)
+	p := palettes[d.subSize(reg)]
+
+	var srcs []source
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			sub, dst := part(cl.Rect, i, j, n, n), part(cl.Dst, i, j, n, n)
+
+			// sub-cells are indexed apart from whole cells
+			index := -(cl.Index*maxSubdivide*maxSubdivide + j*n + i + 1)
+			c := d.cellColor(imtile, sub)
+			im, err := d.match(p, c, index)
+			if err != nil {
+				return nil, err
+			}
+
+			srcs = append(srcs, source{
+				Image:     im,
+				Rect:      dst,
+				Placement: newPlacement(sub, dst, c, im),
+				Cell:      cl,
+				Region:    reg,
+			})
+		}
+	}
+	return srcs, nil
+}
+
+// original leaves a cell as the source image.
+func original(cl cell, c color.Color, reg *Region) source {
+	return source{
+		Rect: cl.Dst,
+		Placement: Placement{
+			Cell:   cl.Rect,
+			Rect:   cl.Dst,
+			Tile:   "original",
+			Target: Hex(c),
+			Color:  Hex(c),
+		},
+		Cell:   cl,
+		Region: reg,
+	}
+}
+
+// DetailMask is an automatic mask for WithMask, brightest where im has
+// the most fine detail (such as faces and text) and darkest over smooth
+// areas like sky. It is the edge strength of im, blurred over a
+// fiftieth of its size and normalised.
+func DetailMask(im image.Image) *image.Gray {
+	b := im.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w < 3 || h < 3 {
+		return image.NewGray(image.Rect(0, 0, w, h))
+	}
+
+	luma := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			luma[y*w+x] = float64(color.GrayModel.Convert(im.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y)
+		}
+	}
+
+	// sobel edge strength, leaving the border at zero
+	edges := make([]float64, w*h)
+	at := func(x, y int) float64 { return luma[y*w+x] }
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			edges[y*w+x] = math.Sqrt(gx*gx + gy*gy)
+		}
+	}
+
+	radius := w / 100
+	if h/100 < radius {
+		radius = h / 100
+	}
+	blurred := boxBlur(boxBlur(edges, w, h, radius, 1, 0), w, h, radius, 0, 1)
+
+	var max float64
+	for _, v := range blurred {
+		max = math.Max(max, v)
+	}
+
+	mask := image.NewGray(image.Rect(0, 0, w, h))
+	if max == 0 {
+		return mask
+	}
+	for i, v := range blurred {
+		mask.Pix[i] = uint8(v/max*255 + 0.5)
+	}
+	return mask
+}
+
+// boxBlur averages v over radius along the axis (dx, dy).
+func boxBlur(v []float64, w, h, radius, dx, dy int) []float64 {
+	out := make([]float64, len(v))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum float64
+			var n int
+			for k := -radius; k <= radius; k++ {
+				px, py := x+k*dx, y+k*dy
+				if px < 0 || px >= w || py < 0 || py >= h {
+					continue
+				}
+				sum += v[py*w+px]
+				n++
+			}
+			out[y*w+x] = sum / float64(n)
+		}
+	}
+	return out
+}
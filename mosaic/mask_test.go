@@ -0,0 +1,131 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// halves is a mask white on its left half and black on its right.
+func halves(size int) *image.Gray {
+	mask := image.NewGray(image.Rect(0, 0, size, size))
+	draw.Draw(mask, image.Rect(0, 0, size/2, size), image.White, image.ZP, draw.Src)
+	return mask
+}
+
+func TestRegion(t *testing.T) {
+	focus, background := Region{Min: 128, Max: 255}, Region{Min: 0, Max: 127}
+	// the mask is stretched over a source twice its size
+	d := NewConverter(fill(color.White, 20), "", WithMask(halves(10), focus, background))
+
+	if reg := d.region(image.Rect(0, 0, 10, 10)); reg == nil || *reg != focus {
+		t.Errorf(errmsg, focus, reg)
+	}
+	if reg := d.region(image.Rect(10, 0, 20, 10)); reg == nil || *reg != background {
+		t.Errorf(errmsg, background, reg)
+	}
+
+	d = NewConverter(fill(color.White, 20), "", WithMask(halves(10), focus))
+	if reg := d.region(image.Rect(10, 0, 20, 10)); reg != nil {
+		t.Errorf(errmsg, nil, reg)
+	}
+}
+
+func TestMaskOriginal(t *testing.T) {
+	// 100 is no web safe color, so tiles differ from the source
+	im := fill(color.RGBA{100, 100, 100, 255}, 20)
+	d := NewConverter(im, "", WithWidth(2), WithHeight(2), WithSize(8),
+		WithMask(halves(20), Region{Min: 128, Max: 255, Original: true}))
+	out, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := color.RGBAModel.Convert(out.At(2, 2)); c != (color.RGBA{100, 100, 100, 255}) {
+		t.Errorf(errmsg, color.RGBA{100, 100, 100, 255}, c)
+	}
+	if c := color.RGBAModel.Convert(out.At(12, 2)); c != (color.RGBA{102, 102, 102, 255}) {
+		t.Errorf(errmsg, color.RGBA{102, 102, 102, 255}, c)
+	}
+
+	var originals int
+	for _, p := range d.Manifest() {
+		if p.Tile == "original" {
+			originals++
+		}
+	}
+	if originals != 2 {
+		t.Errorf(errmsg, 2, originals)
+	}
+}
+
+func TestMaskSubdivide(t *testing.T) {
+	im := fill(color.RGBA{100, 100, 100, 255}, 20)
+	for _, dither := range []DitherKernel{DitherNone, DitherFloydSteinberg} {
+		d := NewConverter(im, "", WithWidth(2), WithHeight(2), WithSize(8), WithDithering(dither),
+			WithMask(halves(20), Region{Min: 128, Max: 255, Subdivide: 2}))
+		if _, err := d.Decode(); err != nil {
+			t.Fatal(err)
+		}
+
+		// two whole cells and two split in to four
+		m := d.Manifest()
+		if len(m) != 10 {
+			t.Errorf(errmsg, 10, len(m))
+		}
+		if p, ok := m.At(image.Pt(5, 5)); !ok || p.Rect != image.Rect(4, 4, 8, 8) {
+			t.Errorf(errmsg, image.Rect(4, 4, 8, 8), p.Rect)
+		}
+	}
+}
+
+func TestMaskSubdivideGrid(t *testing.T) {
+	mask := image.NewGray(image.Rect(0, 0, 16, 16))
+	draw.Draw(mask, mask.Bounds(), image.White, image.ZP, draw.Src)
+	d := NewConverter(quads(16), "", WithWidth(1), WithHeight(1), WithSize(8),
+		WithMask(mask, Region{Min: 128, Max: 255, Subdivide: 2}))
+
+	grid, err := d.Grid()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the mean of red, green, blue and yellow quadrants
+	expected := color.RGBA64{0x7fff, 0x7fff, 0x3fff, 0xffff}
+	if c := grid[0][0]; c != expected {
+		t.Errorf(errmsg, expected, c)
+	}
+}
+
+func TestMaskAlpha(t *testing.T) {
+	im := fill(color.RGBA{100, 100, 100, 255}, 20)
+	d := NewConverter(im, "", WithWidth(2), WithHeight(2), WithSize(8),
+		WithMask(halves(20), Region{Min: 128, Max: 255, Alpha: 1}))
+	out, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the near transparent tiles leave the source showing through
+	if c := color.RGBAModel.Convert(out.At(2, 2)); c != (color.RGBA{100, 100, 100, 255}) {
+		t.Errorf(errmsg, color.RGBA{100, 100, 100, 255}, c)
+	}
+	if c := color.RGBAModel.Convert(out.At(12, 2)); c != (color.RGBA{102, 102, 102, 255}) {
+		t.Errorf(errmsg, color.RGBA{102, 102, 102, 255}, c)
+	}
+}
+
+func TestDetailMask(t *testing.T) {
+	// a sharp edge down the middle of the right half
+	im := fill(color.White, 40)
+	draw.Draw(im, image.Rect(30, 0, 40, 40), image.Black, image.ZP, draw.Src)
+
+	mask := DetailMask(im)
+	if mask.Bounds() != im.Bounds() {
+		t.Errorf(errmsg, im.Bounds(), mask.Bounds())
+	}
+	if edge, flat := mask.GrayAt(30, 20).Y, mask.GrayAt(10, 20).Y; edge <= flat {
+		t.Errorf("Expected edge %d brighter than flat %d\n", edge, flat)
+	}
+}
@@ -0,0 +1,336 @@
+package mosaic
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// Unlimited is the Quantity of a material with no stock limit.
+const Unlimited = -1
+
+// Material is a physical piece used to build a mosaic, such as a brick,
+// tile or bead of a single color.
+type Material struct {
+	Code     string
+	Name     string
+	Color    color.Color
+	Quantity int
+}
+
+// LoadMaterials reads a materials CSV file, see ParseMaterials.
+func LoadMaterials(path string) ([]Material, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+	return ParseMaterials(fi)
+}
+
+// ParseMaterials reads materials as CSV with the header
+// code,name,color,quantity where color is a hex color. An empty
+// quantity or one of -1 is Unlimited.
+func ParseMaterials(r io.Reader) ([]Material, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 4
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 || strings.ToLower(records[0][0]) != "code" {
+		return nil, fmt.Errorf("expected header code,name,color,quantity")
+	}
+
+	materials := make([]Material, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		c, err := ParseHexColor(rec[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+2, err)
+		}
+
+		qty := Unlimited
+		if rec[3] != "" {
+			if qty, err = strconv.Atoi(rec[3]); err != nil || qty < Unlimited {
+				return nil, fmt.Errorf("line %d: invalid quantity %q", i+2, rec[3])
+			}
+		}
+
+		materials = append(materials, Material{
+			Code:     rec[0],
+			Name:     rec[1],
+			Color:    c,
+			Quantity: qty,
+		})
+	}
+
+	if len(materials) == 0 {
+		return nil, EmptyPalette{}
+	}
+	return materials, nil
+}
+
+// BuildPlan assigns a material to every cell of a mosaic, as produced
+// by Converter.Plan.
+type BuildPlan struct {
+	Width, Height int
+	Materials     []Material
+	// Cells are ordered by row and then column
+	Cells []BuildCell
+}
+
+// BuildCell is a single cell of a BuildPlan, Material indexes in to the
+// plan's Materials.
+type BuildCell struct {
+	Row, Col int
+	Material int
+	Target   color.Color
+	DeltaE   float64
+}
+
+// BillItem is the number of pieces of a material a plan uses, along
+// with how many remain in stock afterwards (Unlimited for materials
+// without a limit).
+type BillItem struct {
+	Material
+	Used      int
+	Remaining int
+}
+
+// Plan matches every cell of the source image to one of materials
+// without exceeding the quantity of any of them. Closest matches are
+// placed first, so when a color runs out the cells it suits least are
+// the ones moved on to their next best material.
+func (d *Converter) Plan(materials []Material) (*BuildPlan, error) {
+	if len(materials) == 0 {
+		return nil, EmptyPalette{}
+	}
+
+	proc := make(chan []cell, d.procBuffer)
+	go d.bounds(proc, d.im.Bounds())
+
+	var (
+		imtile = NewImageTile(d.im)
+		plan   = &BuildPlan{Materials: materials}
+		stock  = 0
+	)
+
+	for cell := range cells(proc) {
+		plan.Cells = append(plan.Cells, BuildCell{
+			Row:      cell.Row,
+			Col:      cell.Col,
+			Material: -1,
+			Target:   imtile.MeanAt(cell.Rect),
+		})
+		if cell.Row+1 > plan.Height {
+			plan.Height = cell.Row + 1
+		}
+		if cell.Col+1 > plan.Width {
+			plan.Width = cell.Col + 1
+		}
+	}
+
+	remaining := make([]int, len(materials))
+	for i, m := range materials {
+		remaining[i] = m.Quantity
+		if m.Quantity == Unlimited || stock == Unlimited {
+			stock = Unlimited
+			continue
+		}
+		stock += m.Quantity
+	}
+	if stock != Unlimited && stock < len(plan.Cells) {
+		return nil, InsufficientStock{Cells: len(plan.Cells), Stock: stock}
+	}
+
+	// rank every cell and material pairing by closeness
+	pairs := make([]pairing, 0, len(plan.Cells)*len(materials))
+	for i, cell := range plan.Cells {
+		for j, m := range materials {
+			pairs = append(pairs, pairing{cell: i, material: j, dist: palette.DeltaE(cell.Target, m.Color)})
+		}
+	}
+	sort.Stable(byDist(pairs))
+
+	placed := 0
+	for _, p := range pairs {
+		cell := &plan.Cells[p.cell]
+		if cell.Material >= 0 || remaining[p.material] == 0 {
+			continue
+		}
+		cell.Material, cell.DeltaE = p.material, p.dist
+		if remaining[p.material] != Unlimited {
+			remaining[p.material]--
+		}
+		if placed++; placed == len(plan.Cells) {
+			break
+		}
+	}
+
+	return plan, nil
+}
+
+// Bill returns the quantity of each material used by the plan, in the
+// order the materials were given. Materials which are not used are
+// omitted.
+func (p *BuildPlan) Bill() []BillItem {
+	used := make([]int, len(p.Materials))
+	for _, cell := range p.Cells {
+		used[cell.Material]++
+	}
+
+	var bill []BillItem
+	for i, m := range p.Materials {
+		if used[i] == 0 {
+			continue
+		}
+		item := BillItem{Material: m, Used: used[i], Remaining: Unlimited}
+		if m.Quantity != Unlimited {
+			item.Remaining = m.Quantity - used[i]
+		}
+		bill = append(bill, item)
+	}
+	return bill
+}
+
+// WriteCSV writes the plan one cell per line with its row, column and
+// material. Rows and columns are numbered from 1.
+func (p *BuildPlan) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"row", "col", "code", "name", "color", "target", "delta_e"}); err != nil {
+		return err
+	}
+
+	for _, cell := range p.Cells {
+		m := p.Materials[cell.Material]
+		if err := cw.Write([]string{
+			strconv.Itoa(cell.Row + 1),
+			strconv.Itoa(cell.Col + 1),
+			m.Code,
+			m.Name,
+			Hex(m.Color),
+			Hex(cell.Target),
+			strconv.FormatFloat(cell.DeltaE, 'f', 4, 64),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteBill writes the bill of materials as CSV.
+func (p *BuildPlan) WriteBill(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"code", "name", "color", "used", "remaining"}); err != nil {
+		return err
+	}
+
+	for _, item := range p.Bill() {
+		remaining := ""
+		if item.Remaining != Unlimited {
+			remaining = strconv.Itoa(item.Remaining)
+		}
+		if err := cw.Write([]string{
+			item.Code,
+			item.Name,
+			Hex(item.Color),
+			strconv.Itoa(item.Used),
+			remaining,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteGrid writes the plan as a printable grid of material codes with
+// numbered rows and columns, followed by a key of the codes used.
+func (p *BuildPlan) WriteGrid(w io.Writer) error {
+	width := len(strconv.Itoa(p.Width))
+	for _, m := range p.Materials {
+		if len(m.Code) > width {
+			width = len(m.Code)
+		}
+	}
+	margin := len(strconv.Itoa(p.Height))
+
+	grid := make([][]string, p.Height)
+	for i := range grid {
+		grid[i] = make([]string, p.Width)
+	}
+	for _, cell := range p.Cells {
+		grid[cell.Row][cell.Col] = p.Materials[cell.Material].Code
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%*s", margin, "")
+	for col := 1; col <= p.Width; col++ {
+		fmt.Fprintf(&b, " %*d", width, col)
+	}
+	b.WriteByte('\n')
+
+	for row, codes := range grid {
+		fmt.Fprintf(&b, "%*d", margin, row+1)
+		for _, code := range codes {
+			fmt.Fprintf(&b, " %*s", width, code)
+		}
+		b.WriteByte('\n')
+	}
+
+	b.WriteByte('\n')
+	for _, item := range p.Bill() {
+		fmt.Fprintf(&b, "%*s  %s %s x%d\n", width, item.Code, Hex(item.Color), item.Name, item.Used)
+	}
+
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// Image renders the plan with each cell drawn as a size by size square
+// of its material color, separated by a one pixel grid line.
+func (p *BuildPlan) Image(size int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, p.Width*size+1, p.Height*size+1))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.Gray{Y: 64}), image.ZP, draw.Src)
+	for _, cell := range p.Cells {
+		x, y := cell.Col*size, cell.Row*size
+		rect := image.Rect(x+1, y+1, x+size, y+size)
+		draw.Draw(dst, rect, image.NewUniform(p.Materials[cell.Material].Color), image.ZP, draw.Src)
+	}
+	return dst
+}
+
+type pairing struct {
+	cell, material int
+	dist           float64
+}
+
+type byDist []pairing
+
+func (b byDist) Len() int           { return len(b) }
+func (b byDist) Less(i, j int) bool { return b[i].dist < b[j].dist }
+func (b byDist) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// errors
+
+type InsufficientStock struct {
+	Cells, Stock int
+}
+
+func (i InsufficientStock) Error() string {
+	return fmt.Sprintf("plan needs %d pieces but only %d are in stock", i.Cells, i.Stock)
+}
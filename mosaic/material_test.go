@@ -0,0 +1,171 @@
+package mosaic
+
+import (
+	"bytes"
+	"encoding/csv"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+var materialsCSV = `code,name,color,quantity
+R,Red,#ff0000,10
+B,Blue,#0000ff,
+G,Green,#00ff00,0
+`
+
+func TestParseMaterials(t *testing.T) {
+	m, err := ParseMaterials(strings.NewReader(materialsCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m) != 3 {
+		t.Fatalf(errmsg, 3, len(m))
+	}
+	if m[0].Code != "R" || m[0].Name != "Red" || m[0].Quantity != 10 {
+		t.Errorf(errmsg, "R Red 10", m[0])
+	}
+	if m[1].Quantity != Unlimited {
+		t.Errorf(errmsg, Unlimited, m[1].Quantity)
+	}
+	if c := (color.RGBA{0, 255, 0, 255}); m[2].Color != c {
+		t.Errorf(errmsg, c, m[2].Color)
+	}
+
+	for _, bad := range []string{
+		"R,Red,#ff0000,10\n",
+		"code,name,color,quantity\nR,Red,#ff00,10\n",
+		"code,name,color,quantity\nR,Red,#ff0000,lots\n",
+	} {
+		if _, err := ParseMaterials(strings.NewReader(bad)); err == nil {
+			t.Errorf(errmsg, "error", err)
+		}
+	}
+}
+
+// red returns materials in red and orange with the given stock.
+func red(reds, oranges int) []Material {
+	return []Material{
+		{Code: "R", Name: "Red", Color: color.RGBA{255, 0, 0, 255}, Quantity: reds},
+		{Code: "O", Name: "Orange", Color: color.RGBA{255, 102, 0, 255}, Quantity: oranges},
+	}
+}
+
+func TestPlanRespectsStock(t *testing.T) {
+	im := fill(color.RGBA{255, 0, 0, 255}, 40)
+	d := NewConverter(im, "", WithWidth(4), WithHeight(4))
+
+	plan, err := d.Plan(red(10, Unlimited))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.Width != 4 || plan.Height != 4 || len(plan.Cells) != 16 {
+		t.Fatalf(errmsg, "4x4", plan)
+	}
+
+	bill := plan.Bill()
+	if len(bill) != 2 {
+		t.Fatalf(errmsg, 2, len(bill))
+	}
+	if bill[0].Used != 10 || bill[0].Remaining != 0 {
+		t.Errorf(errmsg, "10 used 0 remaining", bill[0])
+	}
+	if bill[1].Used != 6 || bill[1].Remaining != Unlimited {
+		t.Errorf(errmsg, "6 used unlimited remaining", bill[1])
+	}
+
+	if _, err := d.Plan(red(10, 5)); err != (InsufficientStock{Cells: 16, Stock: 15}) {
+		t.Errorf(errmsg, InsufficientStock{Cells: 16, Stock: 15}, err)
+	}
+}
+
+func TestPlanPrefersClosestCells(t *testing.T) {
+	// left half red, right half orange
+	im := fill(color.RGBA{255, 0, 0, 255}, 40)
+	for y := 0; y < 40; y++ {
+		for x := 20; x < 40; x++ {
+			im.Set(x, y, color.RGBA{255, 102, 0, 255})
+		}
+	}
+
+	plan, err := NewConverter(im, "", WithWidth(4), WithHeight(4)).Plan(red(12, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the orange cells are the last to take the surplus red
+	for _, cell := range plan.Cells {
+		if cell.Col < 2 && cell.Material != 0 {
+			t.Errorf(errmsg, "red", cell)
+		}
+	}
+}
+
+func TestPlanTrueCellColors(t *testing.T) {
+	// both bin to web safe #cc0000, but match distinct materials
+	im := fill(color.RGBA{190, 0, 0, 255}, 20)
+	for y := 0; y < 20; y++ {
+		for x := 10; x < 20; x++ {
+			im.Set(x, y, color.RGBA{220, 0, 0, 255})
+		}
+	}
+	materials := []Material{
+		{Code: "D", Name: "Dark red", Color: color.RGBA{190, 0, 0, 255}, Quantity: Unlimited},
+		{Code: "L", Name: "Light red", Color: color.RGBA{220, 0, 0, 255}, Quantity: Unlimited},
+	}
+
+	plan, err := NewConverter(im, "", WithWidth(2), WithHeight(1)).Plan(materials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cell := range plan.Cells {
+		if cell.Material != cell.Col {
+			t.Errorf(errmsg, materials[cell.Col].Name, cell)
+		}
+	}
+}
+
+func TestBuildPlanWriters(t *testing.T) {
+	im := fill(color.RGBA{255, 0, 0, 255}, 20)
+	plan, err := NewConverter(im, "", WithWidth(2), WithHeight(2)).Plan(red(Unlimited, Unlimited))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := plan.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Fatalf(errmsg, 5, len(records))
+	}
+	if rec := strings.Join(records[4][:5], ","); rec != "2,2,R,Red,#ff0000" {
+		t.Errorf(errmsg, "2,2,R,Red,#ff0000", rec)
+	}
+
+	buf.Reset()
+	if err := plan.WriteBill(buf); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "code,name,color,used,remaining\nR,Red,#ff0000,4,\n"; buf.String() != expected {
+		t.Errorf(errmsg, expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := plan.WriteGrid(buf); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "  1 2\n1 R R\n2 R R\n\nR  #ff0000 Red x4\n"; buf.String() != expected {
+		t.Errorf(errmsg, expected, buf.String())
+	}
+
+	if size := plan.Image(10).Bounds().Size(); size.X != 21 || size.Y != 21 {
+		t.Errorf(errmsg, "21x21", size)
+	}
+}
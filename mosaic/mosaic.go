@@ -1,10 +1,10 @@
 package mosaic
 
 import (
-	"fmt"
 	"image"
 	"log"
-	"math"
+	"runtime"
+	"sort"
 	"sync"
 
 	"github.com/GeorgeMac/gomosaic/mosaic/palette"
@@ -23,17 +23,41 @@ type Converter struct {
 	generator           palette.Generator
 	width, height, size int
 	alpha               uint8
+	gaps                GapPolicy
+	fallback            color.Color
+	maxDistance         float64
+	manifest            Manifest
+	seed                int64
+	deterministic       bool
+	workers             int
+	procBuffer          int
+	compBuffer          int
+	rows                bool
+	dither              DitherKernel
+	coherence           float64
+	history             *history
+	blend               BlendMode
+	adaptiveMin         uint8
+	adaptiveDeltaE      float64
+	mask                image.Image
+	regions             []Region
+	mean                bool
+	space               palette.Space
+	linkDir             string
 }
 
 func NewConverter(im image.Image, term string, opts ...option) *Converter {
 	d := &Converter{
-		im:        im,
-		term:      term,
-		width:     100,
-		height:    100,
-		size:      100,
-		alpha:     255,
-		generator: palette.GeneratorFunc(NewUniformWebColorPalette),
+		im:          im,
+		term:        term,
+		width:       100,
+		height:      100,
+		size:        100,
+		alpha:       255,
+		generator:   palette.GeneratorFunc(NewUniformWebColorPalette),
+		maxDistance: noMaxDistance,
+		workers:     runtime.GOMAXPROCS(0),
+		procBuffer:  2,
 	}
 
 	for _, opt := range opts {
@@ -45,13 +69,13 @@ func NewConverter(im image.Image, term string, opts ...option) *Converter {
 
 func (d *Converter) Decode() (image.Image, error) {
 	// tiles to process channel
-	proc := make(chan image.Rectangle, 2)
+	proc := make(chan []cell, d.procBuffer)
 	// tiles to compose
-	comp := make(chan source)
+	comp := make(chan source, d.compBuffer)
 	// resized image promise
 	scaled := make(chan draw.Image)
-	// error channel for failed palette generation
-	errc := make(chan error)
+	// error channel for failed palette generation or tile matching
+	errc := make(chan error, 1)
 
 	bounds := d.im.Bounds()
 	// initial image bounds
@@ -59,7 +83,7 @@ func (d *Converter) Decode() (image.Image, error) {
 	ny := d.height * d.size
 	sx, sy := float64(nx)/float64(bounds.Dx()), float64(ny)/float64(bounds.Dy())
 
-	fmt.Printf("[mosaic] Original [%d, %d] New [%d, %d] Scale [%d, %d]\n", bounds.Dx(), bounds.Dy(), nx, ny, sx, sy)
+	log.Printf("[mosaic] Original [%d, %d] New [%d, %d] Scale [%.2f, %.2f]\n", bounds.Dx(), bounds.Dy(), nx, ny, sx, sy)
 
 	log.Println("[mosaic] Begin resizing")
 	go func() {
@@ -77,119 +101,260 @@ func (d *Converter) Decode() (image.Image, error) {
 
 	// average calculation go routines
 	log.Println("[mosaic] Fetching color information")
-	go d.process(proc, comp, errc, sx, sy)
+	go d.process(proc, comp, errc)
 
 	// tile composition routine
 	log.Println("[mosaic] Composing image")
-	mask := image.NewUniform(color.Alpha{A: d.alpha})
 	// get scaled source image
 	dst := <-scaled
-	if err := func() error {
-		for {
-			select {
-			case tile, ok := <-comp:
-				if !ok {
-					return nil
-				}
-				draw.DrawMask(dst, tile.Rect, tile.Image, image.ZP, mask, image.ZP, draw.Over)
-			case err := <-errc:
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}(); err != nil {
+	d.manifest = nil
+	for tile := range comp {
+		d.compose(dst, tile)
+		d.manifest = append(d.manifest, tile.Placement)
+	}
+	sort.Sort(d.manifest)
+
+	if err := <-errc; err != nil {
 		return nil, err
 	}
 
 	return dst, nil
 }
 
-func (d *Converter) bounds(proc chan<- image.Rectangle, bounds image.Rectangle) {
-	x, y := bounds.Min.X, bounds.Min.Y
-	dx := int(math.Ceil(float64(bounds.Max.X / d.width)))
-	dy := int(math.Ceil(float64(bounds.Max.Y / d.height)))
-
-	x1 := x
-	x2 := x + dx
-	for {
-		// don't let x1 exceed max X
-		if x2 > bounds.Max.X {
-			x2 = bounds.Max.X
-		}
-
-		y1 := y
-		y2 := y + dx
-		for {
-			// don't let y1 exceed max Y
-			if y2 > bounds.Max.Y {
-				y2 = bounds.Max.Y
-			}
+// Manifest returns a record of which tile was placed in each cell by
+// the most recent call to Decode, EncodeSVG or Grid.
+func (d *Converter) Manifest() Manifest {
+	return d.manifest
+}
 
-			// create rectangle view
-			proc <- image.Rect(x1, y1, x2, y2)
+// bounds splits the source image in to a grid of exactly width by height
+// cells, sending them to proc one at a time, or a row at a time when
+// batching rows.
+func (d *Converter) bounds(proc chan<- []cell, bounds image.Rectangle) {
+	defer close(proc)
 
-			// break now because we have reached the max boundary
-			if y1+dy >= bounds.Max.Y {
-				break
+	mosaic := image.Rect(0, 0, d.width*d.size, d.height*d.size)
+	var index int
+	for r := 0; r < d.height; r++ {
+		row := make([]cell, 0, d.width)
+		for col := 0; col < d.width; col++ {
+			c := cell{
+				Index: index,
+				Row:   r,
+				Col:   col,
+				Rect:  part(bounds, col, r, d.width, d.height),
+				Dst:   part(mosaic, col, r, d.width, d.height),
+			}
+			index++
+			if d.rows {
+				row = append(row, c)
+				continue
 			}
-			// increase y by dy
-			y1 = y2
-			y2 += dy
+			proc <- []cell{c}
 		}
-		// break now because we have reached the max boundary
-		if x1+dx >= bounds.Max.X {
-			break
+		if d.rows {
+			proc <- row
 		}
-		// increase x by dx
-		x1 = x2
-		x2 += dx
 	}
-	close(proc)
 }
 
-func (d *Converter) process(proc <-chan image.Rectangle, comp chan<- source, errc chan<- error, sx, sy float64) {
-	var wg sync.WaitGroup
+func (d *Converter) process(proc <-chan []cell, comp chan<- source, errc chan<- error) {
+	defer close(errc)
+	defer close(comp)
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		done = make(chan struct{})
+	)
 	imtile := NewImageTile(d.im)
 	p, err := d.generator.Palette(d.term, d.size)
 	if err != nil {
 		log.Println("[mosaic] Error creating palette")
-		close(comp)
 		errc <- err
-		close(errc)
+		// drain remaining work so the bounds routine can exit
+		for range proc {
+		}
 		return
 	}
-	for i := 0; i < 10; i++ {
+
+	palettes, err := d.palettes(p)
+	if err != nil {
+		log.Println("[mosaic] Error creating palette")
+		errc <- err
+		for range proc {
+		}
+		return
+	}
+	for _, p := range palettes {
+		matchIn(p, d.space)
+	}
+
+	if d.dither != DitherNone {
+		if err := d.dithered(proc, comp, palettes, imtile); err != nil {
+			errc <- err
+		}
+		return
+	}
+
+	for i := 0; i < d.workers; i++ {
 		wg.Add(1)
 		go func() {
-			for rect := range proc {
-				c := imtile.ColorAt(rect)
-				min, max := rect.Min, rect.Max
-				comp <- source{
-					Image: p.Convert(palette.NewColorKey(c)),
-					Rect: image.Rectangle{
-						Min: image.Point{
-							X: int(math.Floor(float64(min.X) * sx)),
-							Y: int(math.Floor(float64(min.Y) * sy)),
-						},
-						Max: image.Point{
-							X: int(math.Floor(float64(max.X) * sx)),
-							Y: int(math.Floor(float64(max.Y) * sy)),
-						},
-					},
+			for batch := range proc {
+				for _, cell := range batch {
+					// skip remaining work once a worker has failed
+					select {
+					case <-done:
+						continue
+					default:
+					}
+
+					c := d.cellColor(imtile, cell.Rect)
+					srcs, err := d.render(palettes, imtile, cell, c)
+					if err != nil {
+						once.Do(func() {
+							errc <- err
+							close(done)
+						})
+						continue
+					}
+
+					for _, s := range srcs {
+						comp <- s
+					}
 				}
 			}
 			wg.Done()
 		}()
 	}
 	wg.Wait()
-	close(comp)
-	close(errc)
+}
+
+// render matches the cell of color c to its tiles, following the
+// region of the mask it lies in.
+func (d *Converter) render(palettes map[int]palette.Palette, imtile *ImageTile, cl cell, c color.Color) ([]source, error) {
+	reg := d.region(cl.Rect)
+	switch {
+	case reg != nil && reg.Original:
+		return []source{original(cl, c, reg)}, nil
+	case subdivisions(reg) > 1:
+		return d.subdivided(palettes, imtile, cl, reg)
+	}
+
+	im, err := d.match(palettes[d.size], c, cl.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	return []source{{
+		Image:     im,
+		Rect:      cl.Dst,
+		Placement: newPlacement(cl.Rect, cl.Dst, c, im),
+		Cell:      cl,
+		Region:    reg,
+	}}, nil
+}
+
+// cell is a region of the source image along with its position in the
+// order cells are generated, which is used to select tiles
+// deterministically.
+type cell struct {
+	Index    int
+	Row, Col int
+	Rect     image.Rectangle
+	// Dst is where the cell lies in the mosaic
+	Dst image.Rectangle
+}
+
+// cellColor returns the color the region r of the source is matched on.
+// Dithering always samples the mean color, as the most common web safe
+// color of a cell leaves no error to diffuse.
+func (d *Converter) cellColor(imtile *ImageTile, r image.Rectangle) color.Color {
+	if d.mean || d.dither != DitherNone {
+		return imtile.MeanAt(r)
+	}
+	return imtile.ColorAt(r)
+}
+
+// part returns the cell at (col, row) of r split in to a cols by rows
+// grid. Cells are at least a pixel in size, so they overlap rather than
+// vanish when r is smaller than the grid.
+func part(r image.Rectangle, col, row, cols, rows int) image.Rectangle {
+	p := image.Rect(
+		r.Min.X+r.Dx()*col/cols, r.Min.Y+r.Dy()*row/rows,
+		r.Min.X+r.Dx()*(col+1)/cols, r.Min.Y+r.Dy()*(row+1)/rows,
+	)
+	if p.Dx() < 1 {
+		p.Max.X = p.Min.X + 1
+	}
+	if p.Dy() < 1 {
+		p.Max.Y = p.Min.Y + 1
+	}
+	return p
+}
+
+// sources matches every cell of the source image to a tile as Decode
+// does, returning them ordered by cell without rendering them.
+func (d *Converter) sources() ([]source, error) {
+	proc := make(chan []cell, d.procBuffer)
+	comp := make(chan source, d.compBuffer)
+	errc := make(chan error, 1)
+
+	go d.bounds(proc, d.im.Bounds())
+	go d.process(proc, comp, errc)
+
+	var srcs []source
+	d.manifest = nil
+	for s := range comp {
+		srcs = append(srcs, s)
+		d.manifest = append(d.manifest, s.Placement)
+	}
+	sort.Sort(d.manifest)
+
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	sort.Sort(byCell(srcs))
+	return srcs, nil
+}
+
+// cells flattens batches of cells from proc in to a single stream.
+func cells(proc <-chan []cell) <-chan cell {
+	out := make(chan cell)
+	go func() {
+		defer close(out)
+		for batch := range proc {
+			for _, c := range batch {
+				out <- c
+			}
+		}
+	}()
+	return out
 }
 
 // window contains an image to render + a target rectangle
 // view to render it in to.
 type source struct {
-	Image image.Image
-	Rect  image.Rectangle
+	Image     image.Image
+	Rect      image.Rectangle
+	Placement Placement
+	Cell      cell
+	// Region is the region of the mask the tile lies in, if any
+	Region *Region
+}
+
+type byCell []source
+
+func (b byCell) Len() int      { return len(b) }
+func (b byCell) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+
+// Less orders sub-cells of a subdivided cell by their position.
+func (b byCell) Less(i, j int) bool {
+	if b[i].Cell.Index != b[j].Cell.Index {
+		return b[i].Cell.Index < b[j].Cell.Index
+	}
+	if b[i].Rect.Min.Y != b[j].Rect.Min.Y {
+		return b[i].Rect.Min.Y < b[j].Rect.Min.Y
+	}
+	return b[i].Rect.Min.X < b[j].Rect.Min.X
 }
@@ -0,0 +1,178 @@
+package mosaic
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+func tilePalette(tiles []palette.Tile) palette.Generator {
+	return palette.GeneratorFunc(func(_ string, size int) (palette.Palette, error) {
+		return NewTilePalette(tiles, size), nil
+	})
+}
+
+func TestConverter_DecodeUniform(t *testing.T) {
+	d := NewConverter(gradient(64, 64), "",
+		WithWidth(8),
+		WithHeight(8),
+		WithSize(4),
+		WithSeed(1))
+
+	im, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := im.Bounds().Size(); size != image.Pt(32, 32) {
+		t.Errorf(errmsg, image.Pt(32, 32), size)
+	}
+
+	if n := len(d.Manifest()); n != 64 {
+		t.Errorf(errmsg, 64, n)
+	}
+
+	golden(t, "uniform", im)
+}
+
+func TestConverter_DecodeIndivisible(t *testing.T) {
+	// 10px doesn't divide in to 6 cells, and 240 isn't web safe so any
+	// cell left without a tile shows the source
+	d := NewConverter(fill(color.RGBA{0, 240, 0, 255}, 10), "",
+		WithWidth(6),
+		WithHeight(6),
+		WithSize(3),
+		WithBuffers(-1, -1))
+
+	im, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(d.Manifest()); n != 36 {
+		t.Errorf(errmsg, 36, n)
+	}
+	for y := 0; y < 18; y++ {
+		for x := 0; x < 18; x++ {
+			if c := color.RGBAModel.Convert(im.At(x, y)); c != (color.RGBA{0, 255, 0, 255}) {
+				t.Fatalf("(%d, %d): "+errmsg, x, y, color.RGBA{0, 255, 0, 255}, c)
+			}
+		}
+	}
+}
+
+func TestConverter_DecodeConcurrency(t *testing.T) {
+	// a grid which doesn't divide the source evenly, with the source
+	// showing through the tiles
+	var first *image.RGBA
+	for _, workers := range []int{1, 2, 7} {
+		for _, rows := range []bool{false, true} {
+			for _, buffer := range []int{0, 16} {
+				d := NewConverter(gradient(70, 50), "",
+					WithWidth(9),
+					WithHeight(7),
+					WithSize(4),
+					WithAlpha(200),
+					WithWorkers(workers),
+					WithBuffers(buffer, buffer),
+					WithRowBatching(rows))
+				im, err := d.Decode()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n := len(d.Manifest()); n != 63 {
+					t.Errorf(errmsg, 63, n)
+				}
+
+				out := rgba(im)
+				if first == nil {
+					first = out
+					continue
+				}
+				if out.Bounds() != first.Bounds() || !bytes.Equal(out.Pix, first.Pix) {
+					t.Errorf("workers %d, rows %t, buffers %d: output differs from 1 worker\n", workers, rows, buffer)
+				}
+			}
+		}
+	}
+}
+
+func TestConverter_DecodeImageTiles(t *testing.T) {
+	d := NewConverter(gradient(96, 96), "",
+		WithWidth(12),
+		WithHeight(12),
+		WithSize(8),
+		WithAlpha(200),
+		WithSeed(1),
+		WithPaletteGenerator(tilePalette(tileSet(8, 8))))
+
+	im, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range d.Manifest() {
+		if p.Tile == "" {
+			t.Errorf(errmsg, "tile source", p)
+		}
+	}
+
+	golden(t, "tiles", im)
+}
+
+func TestConverter_DecodeGaps(t *testing.T) {
+	red := NewImageTile(fill(color.RGBA{255, 0, 0, 255}, 4))
+	gen := tilePalette([]palette.Tile{red})
+	src := fill(color.RGBA{0, 0, 255, 255}, 32)
+
+	d := NewConverter(src, "",
+		WithWidth(4),
+		WithHeight(4),
+		WithSize(4),
+		WithMaxDistance(10),
+		WithGapPolicy(GapError),
+		WithPaletteGenerator(gen))
+	if _, err := d.Decode(); err == nil {
+		t.Errorf(errmsg, UnmatchedColor{}, err)
+	}
+
+	green := color.RGBA{0, 255, 0, 255}
+	d = NewConverter(src, "",
+		WithWidth(4),
+		WithHeight(4),
+		WithSize(4),
+		WithMaxDistance(10),
+		WithGapPolicy(GapFallbackColor),
+		WithFallbackColor(green),
+		WithPaletteGenerator(gen))
+	im, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.RGBAModel.Convert(im.At(1, 1)); c != green {
+		t.Errorf(errmsg, green, c)
+	}
+}
+
+func TestConverter_DecodeEmptyPalette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosaic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := NewConverter(gradient(32, 32), dir,
+		WithWidth(4),
+		WithHeight(4),
+		WithSize(4),
+		WithPaletteGenerator(palette.GeneratorFunc(NewImageTilePalette)))
+
+	if _, err := d.Decode(); err != (EmptyPalette{}) {
+		t.Errorf(errmsg, EmptyPalette{}, err)
+	}
+}
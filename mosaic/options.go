@@ -1,6 +1,11 @@
 package mosaic
 
-import "github.com/GeorgeMac/gomosaic/mosaic/palette"
+import (
+	"image"
+	"image/color"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
 
 type option func(d *Converter)
 
@@ -33,3 +38,143 @@ func WithPaletteGenerator(g palette.Generator) option {
 		d.generator = g
 	}
 }
+
+// WithGapPolicy sets how cells without a suitable tile are handled.
+func WithGapPolicy(p GapPolicy) option {
+	return func(d *Converter) {
+		d.gaps = p
+	}
+}
+
+// WithFallbackColor sets the color used by the GapFallbackColor policy.
+func WithFallbackColor(c color.Color) option {
+	return func(d *Converter) {
+		d.fallback = c
+	}
+}
+
+// WithMaxDistance treats tiles further than dist (see palette.Distance)
+// from a cell's color as gaps.
+func WithMaxDistance(dist float64) option {
+	return func(d *Converter) {
+		d.maxDistance = dist
+	}
+}
+
+// WithSeed enables deterministic rendering, where the tile chosen for
+// each cell depends only on seed and the position of the cell, so runs
+// with identical inputs produce identical mosaics.
+func WithSeed(seed int64) option {
+	return func(d *Converter) {
+		d.seed = seed
+		d.deterministic = true
+	}
+}
+
+// WithWorkers sets the number of goroutines matching cells to tiles
+// (defaults to GOMAXPROCS).
+func WithWorkers(n int) option {
+	return func(d *Converter) {
+		if n > 0 {
+			d.workers = n
+		}
+	}
+}
+
+// WithBuffers sets the buffer sizes of the channels feeding work to the
+// workers (proc) and matched tiles to composition (comp). Negative
+// sizes are ignored.
+func WithBuffers(proc, comp int) option {
+	return func(d *Converter) {
+		if proc >= 0 {
+			d.procBuffer = proc
+		}
+		if comp >= 0 {
+			d.compBuffer = comp
+		}
+	}
+}
+
+// WithRowBatching hands workers a whole row of cells at a time rather
+// than individual cells, reducing channel overhead for large grids.
+func WithRowBatching(rows bool) option {
+	return func(d *Converter) {
+		d.rows = rows
+	}
+}
+
+// WithColorSpace sets the color space cells are matched to tiles in, for
+// the palettes of this package (defaults to palette.SpaceRGB).
+func WithColorSpace(s palette.Space) option {
+	return func(d *Converter) {
+		d.space = s
+	}
+}
+
+// WithDithering diffuses the color error of each cell to its
+// neighbours using kernel k, which greatly improves fidelity with small
+// palettes. Matching becomes sequential in scan order and cells are
+// matched on their mean color, as with WithMeanColor.
+func WithDithering(k DitherKernel) option {
+	return func(d *Converter) {
+		d.dither = k
+	}
+}
+
+// WithMeanColor matches cells on their mean color rather than their most
+// common web safe color. Palettes whose colors aren't web safe, such as
+// those of NewUniformColorsPalette and NewQuantisedPalette, need it to
+// match true cell colors.
+func WithMeanColor(mean bool) option {
+	return func(d *Converter) {
+		d.mean = mean
+	}
+}
+
+// WithLinkDir sets the directory SVG output is written to. Tiles on disk
+// linked to by SVGTilesLink are referenced relative to it, and without
+// it only remote tiles are linked.
+func WithLinkDir(dir string) option {
+	return func(d *Converter) {
+		d.linkDir = dir
+	}
+}
+
+// WithCoherence keeps the tile matched to a cell from one frame of an
+// animation to the next while the cell's color stays within threshold
+// (ΔE) of the color the tile was matched for, avoiding flicker.
+func WithCoherence(threshold float64) option {
+	return func(d *Converter) {
+		d.coherence = threshold
+	}
+}
+
+// WithBlendMode sets how tiles are combined with the source image
+// beneath them (defaults to BlendNormal).
+func WithBlendMode(m BlendMode) option {
+	return func(d *Converter) {
+		d.blend = m
+	}
+}
+
+// WithAdaptiveAlpha varies the alpha of each tile by how well it
+// matches its cell, from the configured alpha for an exact match down
+// to min for matches deltaE or further away.
+func WithAdaptiveAlpha(min uint8, deltaE float64) option {
+	return func(d *Converter) {
+		d.adaptiveMin = min
+		d.adaptiveDeltaE = deltaE
+	}
+}
+
+// WithMask varies how cells are rendered by region of a grayscale mask,
+// which is stretched over the source image. Each cell takes the first
+// of regions its average mask value falls within, e.g. to subdivide
+// tiles over a face or leave it as the source image. See DetailMask for
+// a mask generated from the source image.
+func WithMask(mask image.Image, regions ...Region) option {
+	return func(d *Converter) {
+		d.mask = mask
+		d.regions = regions
+	}
+}
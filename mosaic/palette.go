@@ -15,13 +15,19 @@ import (
 )
 
 func NewUniformWebColorPalette(_ string, size int) (palette.Palette, error) {
-	tiles := make([]palette.Tile, 0)
-	for _, c := range plt.WebSafe {
+	return NewUniformPalette(plt.WebSafe, size), nil
+}
+
+// NewUniformPalette returns a palette of flat color tiles, one for each
+// color of p.
+func NewUniformPalette(p color.Palette, size int) *TilePalette {
+	tiles := make([]palette.Tile, 0, len(p))
+	for _, c := range p {
 		tiles = append(tiles, &UniformTile{
 			Uniform: image.NewUniform(c),
 		})
 	}
-	return NewTilePalette(tiles, size), nil
+	return NewTilePalette(tiles, size)
 }
 
 func NewImageTilePalette(dir string, size int) (palette.Palette, error) {
@@ -42,12 +48,15 @@ func NewImageTilePalette(dir string, size int) (palette.Palette, error) {
 				if err != nil {
 					return err
 				}
-				im, _, err := image.Decode(fi)
+				defer fi.Close()
+				im, _, err := DecodeImage(fi)
 				if err != nil {
 					return err
 				}
 
-				tile = append(tile, NewImageTile(im))
+				t := NewImageTile(im)
+				t.Source = path
+				tile = append(tile, t)
 				return nil
 			}
 		}
@@ -58,19 +67,27 @@ func NewImageTilePalette(dir string, size int) (palette.Palette, error) {
 		return nil, err
 	}
 
+	if len(tile) == 0 {
+		return nil, EmptyPalette{}
+	}
+
 	return NewTilePalette(tile, size), nil
 }
 
 type TilePalette struct {
 	lookup  map[palette.ColorKey][]palette.Tile
+	next    map[palette.ColorKey]int
 	palette color.Palette
 	mu      sync.Mutex
 	Size    int
+	// Space is the color space tiles are matched in, RGB by default
+	Space palette.Space
 }
 
 func NewTilePalette(tiles []palette.Tile, size int) *TilePalette {
 	t := &TilePalette{
 		lookup:  map[palette.ColorKey][]palette.Tile{},
+		next:    map[palette.ColorKey]int{},
 		palette: color.Palette(make([]color.Color, 0)),
 		Size:    size,
 	}
@@ -87,21 +104,87 @@ func NewTilePalette(tiles []palette.Tile, size int) *TilePalette {
 	return t
 }
 
+// Tiles returns every tile in the palette, in the order they were
+// added.
+func (t *TilePalette) Tiles() []palette.Tile {
+	tiles := make([]palette.Tile, 0, len(t.palette))
+	for _, c := range t.palette {
+		tiles = append(tiles, c.(palette.Tile))
+	}
+	return tiles
+}
+
 func (t *TilePalette) Convert(k palette.ColorKey) palette.Tile {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	tiles := t.candidates(k)
+	if len(tiles) == 0 {
+		return nil
+	}
+
+	// rotate through tiles of the same color
+	key := palette.NewColorKey(tiles[0])
+	n := t.next[key]
+	t.next[key] = (n + 1) % len(tiles)
+	return tiles[n%len(tiles)]
+}
+
+// Candidates returns every tile of the palette color nearest to k, in
+// the order they were added to the palette.
+func (t *TilePalette) Candidates(k palette.ColorKey) []palette.Tile {
+	return t.candidates(k)
+}
+
+func (t *TilePalette) candidates(k palette.ColorKey) []palette.Tile {
+	if len(t.palette) == 0 {
+		return nil
+	}
+	if t.Space != palette.SpaceRGB {
+		return t.lookup[palette.NewColorKey(t.nearest(k.Color()))]
+	}
+
 	// normalise the color in to palette colors
 	c := t.palette.Convert(k.Color())
 
 	// lookup using key from color palette
-	key := palette.NewColorKey(c)
-	if tiles, ok := t.lookup[key]; ok {
-		if len(tiles) == 1 {
-			return tiles[0]
+	if tiles, ok := t.lookup[palette.NewColorKey(c)]; ok {
+		return tiles
+	}
+	return []palette.Tile{t.nearest(k.Color())}
+}
+
+// matchIn sets the color space the tiles of p are matched in, for the
+// palettes of this package. Composites still weigh their sources in RGB,
+// as their threshold is.
+func matchIn(p palette.Palette, s palette.Space) {
+	switch p := p.(type) {
+	case *TilePalette:
+		p.Space = s
+	case *AtlasPalette:
+		p.Space = s
+	case *CompositePalette:
+		for _, g := range p.groups {
+			for _, m := range g.members {
+				matchIn(m.Palette, s)
+			}
+		}
+	}
+}
+
+// nearest linearly scans every tile for the closest match to c within
+// the palette's color space. It is the fallback for when a normalised
+// color is missing from the lookup.
+func (t *TilePalette) nearest(c color.Color) palette.Tile {
+	var (
+		best palette.Tile
+		min  float64
+		desc = t.Space.Describe(c)
+	)
+	for _, p := range t.palette {
+		tile := p.(palette.Tile)
+		if d := desc.Distance(tile); best == nil || d < min {
+			best, min = tile, d
 		}
-		tile := tiles[0]
-		t.lookup[key] = append(tiles[1:], tile)
-		return tile
 	}
-	return nil
+	return best
 }
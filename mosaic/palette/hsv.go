@@ -0,0 +1,98 @@
+package palette
+
+import (
+	"encoding/binary"
+	"image/color"
+	"math"
+)
+
+// HSV is a color described by its hue (0 to 360 degrees), saturation
+// and value (both 0 to 1).
+type HSV struct {
+	H, S, V float64
+}
+
+func NewHSV(c color.Color) HSV {
+	if h, ok := c.(HSV); ok {
+		return h
+	}
+
+	r, g, b, _ := c.RGBA()
+	fr, fg, fb := float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff
+	max := math.Max(fr, math.Max(fg, fb))
+	min := math.Min(fr, math.Min(fg, fb))
+	d := max - min
+
+	h := HSV{V: max}
+	if max > 0 {
+		h.S = d / max
+	}
+	if d == 0 {
+		return h
+	}
+
+	switch max {
+	case fr:
+		h.H = 60 * math.Mod((fg-fb)/d, 6)
+	case fg:
+		h.H = 60 * ((fb-fr)/d + 2)
+	default:
+		h.H = 60 * ((fr-fg)/d + 4)
+	}
+	if h.H < 0 {
+		h.H += 360
+	}
+	return h
+}
+
+// RGBA implements color.Color, converting back to opaque RGB.
+func (h HSV) RGBA() (r, g, b, a uint32) {
+	c := h.V * h.S
+	hh := math.Mod(h.H, 360) / 60
+	x := c * (1 - math.Abs(math.Mod(hh, 2)-1))
+
+	var fr, fg, fb float64
+	switch {
+	case hh < 1:
+		fr, fg = c, x
+	case hh < 2:
+		fr, fg = x, c
+	case hh < 3:
+		fg, fb = c, x
+	case hh < 4:
+		fg, fb = x, c
+	case hh < 5:
+		fr, fb = x, c
+	default:
+		fr, fb = c, x
+	}
+
+	m := h.V - c
+	return uint32(clamp(fr+m) * 0xffff), uint32(clamp(fg+m) * 0xffff), uint32(clamp(fb+m) * 0xffff), 0xffff
+}
+
+// Distance measures the distance between h and o within the HSV cone,
+// so that hue differences matter less as colors become darker or less
+// saturated. It ranges from 0 to 2.
+func (h HSV) Distance(o color.Color) float64 {
+	p := NewHSV(o)
+	x1, y1 := cone(h)
+	x2, y2 := cone(p)
+	dx, dy, dv := x1-x2, y1-y2, h.V-p.V
+	return math.Sqrt(dx*dx + dy*dy + dv*dv)
+}
+
+// Bytes encodes h as 6 bytes, sortable by hue then saturation then
+// value.
+func (h HSV) Bytes() []byte {
+	buf := make([]byte, 6)
+	binary.BigEndian.PutUint16(buf[0:], scale(h.H, 0, 360))
+	binary.BigEndian.PutUint16(buf[2:], scale(h.S, 0, 1))
+	binary.BigEndian.PutUint16(buf[4:], scale(h.V, 0, 1))
+	return buf
+}
+
+func cone(h HSV) (x, y float64) {
+	rad := h.H * math.Pi / 180
+	return h.S * h.V * math.Cos(rad), h.S * h.V * math.Sin(rad)
+}
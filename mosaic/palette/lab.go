@@ -0,0 +1,117 @@
+package palette
+
+import (
+	"encoding/binary"
+	"image/color"
+	"math"
+)
+
+// Lab is a color in CIE L*a*b* space using a D65 white point. L ranges
+// from 0 to 100, while A and B lie roughly within -128 to 127.
+type Lab struct {
+	L, A, B float64
+}
+
+// NewLab converts c from sRGB to CIE L*a*b*.
+func NewLab(c color.Color) Lab {
+	if l, ok := c.(Lab); ok {
+		return l
+	}
+
+	r, g, bl, _ := c.RGBA()
+	lr, lg, lb := linear(r), linear(g), linear(bl)
+
+	x := (0.4124*lr + 0.3576*lg + 0.1805*lb) / whiteX
+	y := 0.2126*lr + 0.7152*lg + 0.0722*lb
+	z := (0.0193*lr + 0.1192*lg + 0.9505*lb) / whiteZ
+
+	fx, fy, fz := labf(x), labf(y), labf(z)
+	return Lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+// RGBA implements color.Color, converting back to opaque sRGB. Colors
+// outside of the sRGB gamut are clamped.
+func (l Lab) RGBA() (r, g, b, a uint32) {
+	fy := (l.L + 16) / 116
+	fx := fy + l.A/500
+	fz := fy - l.B/200
+
+	x, y, z := labfInv(fx)*whiteX, labfInv(fy), labfInv(fz)*whiteZ
+
+	lr := 3.2406*x - 1.5372*y - 0.4986*z
+	lg := -0.9689*x + 1.8758*y + 0.0415*z
+	lb := 0.0557*x - 0.2040*y + 1.0570*z
+
+	return gamma(lr), gamma(lg), gamma(lb), 0xffff
+}
+
+// Distance returns the CIE76 ΔE between l and o.
+func (l Lab) Distance(o color.Color) float64 {
+	m := NewLab(o)
+	dl, da, db := l.L-m.L, l.A-m.A, l.B-m.B
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
+// Bytes encodes l as 6 bytes, sortable by L then A then B.
+func (l Lab) Bytes() []byte {
+	buf := make([]byte, 6)
+	binary.BigEndian.PutUint16(buf[0:], scale(l.L, 0, 100))
+	binary.BigEndian.PutUint16(buf[2:], scale(l.A, -128, 128))
+	binary.BigEndian.PutUint16(buf[4:], scale(l.B, -128, 128))
+	return buf
+}
+
+// DeltaE returns the CIE76 color difference between a and b, which is
+// the euclidean distance between them in CIE L*a*b* space. A value
+// around 2.3 is the smallest difference most people can perceive.
+func DeltaE(a, b color.Color) float64 {
+	return NewLab(a).Distance(b)
+}
+
+// D65 reference white
+const (
+	whiteX = 0.95047
+	whiteZ = 1.08883
+)
+
+// linear converts a 16-bit sRGB component in to linear light.
+func linear(v uint32) float64 {
+	c := float64(v) / 0xffff
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// gamma converts linear light back to a 16-bit sRGB component.
+func gamma(c float64) uint32 {
+	if c <= 0.0031308 {
+		c *= 12.92
+	} else {
+		c = 1.055*math.Pow(c, 1/2.4) - 0.055
+	}
+	return uint32(clamp(c) * 0xffff)
+}
+
+func labf(t float64) float64 {
+	if t > 216.0/24389.0 {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27.0*t + 16) / 116
+}
+
+func labfInv(f float64) float64 {
+	if t := f * f * f; t > 216.0/24389.0 {
+		return t
+	}
+	return (116*f - 16) * 27.0 / 24389.0
+}
+
+// scale maps v within [min, max] on to the range of a uint16.
+func scale(v, min, max float64) uint16 {
+	return uint16(clamp((v-min)/(max-min))*0xffff + 0.5)
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
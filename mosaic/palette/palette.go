@@ -2,8 +2,10 @@ package palette
 
 import (
 	"encoding/binary"
+	"fmt"
 	"image"
 	"image/color"
+	"math"
 )
 
 type Palette interface {
@@ -16,6 +18,19 @@ type Tile interface {
 	ColorAt(image.Rectangle) color.Color
 }
 
+// Candidates is implemented by palettes which can list every tile
+// equally suited to a color without rotating through them, so callers
+// can make their own reproducible choice between them.
+type Candidates interface {
+	Candidates(ColorKey) []Tile
+}
+
+// Named is implemented by tiles which can identify where they came
+// from, such as the path or URL of a tile image.
+type Named interface {
+	Name() string
+}
+
 type Generator interface {
 	Palette(term string, size int) (Palette, error)
 }
@@ -26,32 +41,121 @@ func (p GeneratorFunc) Palette(term string, size int) (Palette, error) {
 	return p(term, size)
 }
 
+// Distance returns the euclidean distance between a and b in 8-bit
+// RGB space, ranging from 0 (identical) to roughly 441.
+func Distance(a, b color.Color) float64 {
+	r1, g1, b1, _ := a.RGBA()
+	r2, g2, b2, _ := b.RGBA()
+	dr := float64(r1>>8) - float64(r2>>8)
+	dg := float64(g1>>8) - float64(g2>>8)
+	db := float64(b1>>8) - float64(b2>>8)
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// ColorKey identifies a color by its 16-bit alpha-premultiplied
+// RGBA components, as returned by color.Color.RGBA.
 type ColorKey [4]uint32
 
+// KeySize is the length in bytes of an encoded ColorKey.
+const KeySize = 8
+
+// legacyKeySize is the length of the original uvarint key encoding.
+const legacyKeySize = 32
+
 func NewColorKey(c color.Color) ColorKey {
 	r, g, b, a := c.RGBA()
 	return [4]uint32{r, g, b, a}
 }
 
+// ColorKeyFromBytes decodes a key produced by Bytes. Keys in the
+// legacy 32 byte encoding are also understood. Malformed input yields
+// the zero key, use ParseColorKey to detect it.
 func ColorKeyFromBytes(b []byte) ColorKey {
+	key, _ := ParseColorKey(b)
+	return key
+}
+
+// ParseColorKey decodes a key in either the compact or the legacy
+// encoding.
+func ParseColorKey(b []byte) (ColorKey, error) {
 	key := ColorKey{}
-	for i := 0; i < len(key); i++ {
-		n := i * 8
-		v, _ := binary.Uvarint(b[n : n+8])
-		key[i] = uint32(v)
+	switch len(b) {
+	case KeySize:
+		for i := range key {
+			key[i] = uint32(binary.BigEndian.Uint16(b[i*2:]))
+		}
+	case legacyKeySize:
+		for i := range key {
+			n := i * 8
+			v, _ := binary.Uvarint(b[n : n+8])
+			key[i] = uint32(v)
+		}
+	default:
+		return key, InvalidKey{Length: len(b)}
 	}
-	return key
+	return key, nil
 }
 
+// Bytes encodes k as 8 bytes, each component as a big-endian uint16 in
+// R, G, B, A order. Byte-wise comparison of encoded keys matches Less,
+// so they can be range scanned in an ordered key/value store.
 func (k ColorKey) Bytes() []byte {
-	buf := make([]byte, 32)
+	buf := make([]byte, KeySize)
 	for i, v := range k {
-		n := i * 8
-		binary.PutUvarint(buf[n:n+8], uint64(v))
+		binary.BigEndian.PutUint16(buf[i*2:], uint16(v))
 	}
 	return buf
 }
 
+// MigrateKey re-encodes a key stored in the legacy 32 byte encoding in
+// the compact encoding. Keys already compact are returned unchanged.
+func MigrateKey(b []byte) ([]byte, error) {
+	key, err := ParseColorKey(b)
+	if err != nil {
+		return nil, err
+	}
+	return key.Bytes(), nil
+}
+
+// Less reports whether k sorts before o, comparing components in
+// R, G, B, A order.
+func (k ColorKey) Less(o ColorKey) bool {
+	for i := range k {
+		if k[i] != o[i] {
+			return k[i] < o[i]
+		}
+	}
+	return false
+}
+
+// Color returns k as an 8-bit color, taking the high byte of each
+// 16-bit component.
 func (k ColorKey) Color() color.Color {
-	return color.RGBA{uint8(k[0]), uint8(k[1]), uint8(k[2]), uint8(k[3])}
+	return color.RGBA{uint8(k[0] >> 8), uint8(k[1] >> 8), uint8(k[2] >> 8), uint8(k[3] >> 8)}
+}
+
+// RGBA implements color.Color.
+func (k ColorKey) RGBA() (r, g, b, a uint32) {
+	return k[0], k[1], k[2], k[3]
+}
+
+// Distance returns the euclidean distance between k and o in 8-bit RGB
+// space, see Distance.
+func (k ColorKey) Distance(o color.Color) float64 {
+	return Distance(k, o)
+}
+
+// RGBA64 returns k as a 16-bit color without loss of precision.
+func (k ColorKey) RGBA64() color.RGBA64 {
+	return color.RGBA64{uint16(k[0]), uint16(k[1]), uint16(k[2]), uint16(k[3])}
+}
+
+// errors
+
+type InvalidKey struct {
+	Length int
+}
+
+func (i InvalidKey) Error() string {
+	return fmt.Sprintf("invalid color key of %d bytes", i.Length)
 }
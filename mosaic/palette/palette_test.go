@@ -1,6 +1,9 @@
 package palette
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
 	"image/color"
 	"math"
 	"testing"
@@ -44,8 +47,8 @@ func TestColorKey_Bytes(t *testing.T) {
 		key := NewColorKey(col)
 
 		buf := key.Bytes()
-		if len(buf) != 32 {
-			t.Errorf(errmsg, 32, len(buf))
+		if len(buf) != KeySize {
+			t.Errorf(errmsg, KeySize, len(buf))
 		}
 
 		newKey := ColorKeyFromBytes(buf)
@@ -59,3 +62,91 @@ func TestColorKey_Bytes(t *testing.T) {
 		}
 	}
 }
+
+func TestColorKey_Color16Bit(t *testing.T) {
+	key := NewColorKey(color.RGBA64{R: 0x12ff, G: 0xab01, B: 0, A: 0xffff})
+
+	expected := color.RGBA{R: 0x12, G: 0xab, B: 0, A: 0xff}
+	if c := key.Color(); c != expected {
+		t.Errorf(errmsg, expected, c)
+	}
+
+	if c := key.RGBA64(); c != (color.RGBA64{R: 0x12ff, G: 0xab01, B: 0, A: 0xffff}) {
+		t.Errorf(errmsg, key, c)
+	}
+}
+
+func TestColorKey_BytesSortable(t *testing.T) {
+	keys := []ColorKey{
+		{0, 0, 0, 0xffff},
+		{0, 0, 0x100, 0xffff},
+		{0, 0xff, 0, 0xffff},
+		{0x1, 0, 0, 0},
+		{0xff00, 0xffff, 0xffff, 0xffff},
+		{0xffff, 0, 0, 0xffff},
+	}
+	for i := 1; i < len(keys); i++ {
+		a, b := keys[i-1], keys[i]
+		if !a.Less(b) || bytes.Compare(a.Bytes(), b.Bytes()) >= 0 {
+			t.Errorf(errmsg, fmt.Sprintf("%v < %v", a, b), "not less")
+		}
+	}
+}
+
+func TestMigrateKey(t *testing.T) {
+	key := NewColorKey(color.RGBA{R: 255, G: 128, B: 3, A: 255})
+
+	// the original 32 byte uvarint encoding
+	legacy := make([]byte, 32)
+	for i, v := range key {
+		binary.PutUvarint(legacy[i*8:i*8+8], uint64(v))
+	}
+
+	if k := ColorKeyFromBytes(legacy); k != key {
+		t.Errorf(errmsg, key, k)
+	}
+
+	migrated, err := MigrateKey(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(migrated, key.Bytes()) {
+		t.Errorf(errmsg, key.Bytes(), migrated)
+	}
+
+	if _, err := MigrateKey([]byte{1, 2, 3}); err != (InvalidKey{Length: 3}) {
+		t.Errorf(errmsg, InvalidKey{Length: 3}, err)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if d := Distance(color.Black, color.Black); d != 0 {
+		t.Errorf(errmsg, 0, d)
+	}
+
+	red := color.RGBA{R: 255, A: 255}
+	if d := Distance(color.Black, red); d != 255 {
+		t.Errorf(errmsg, 255, d)
+	}
+
+	if d, e := Distance(color.Black, color.White), math.Sqrt(3*255*255); d != e {
+		t.Errorf(errmsg, e, d)
+	}
+}
+
+func TestDeltaE(t *testing.T) {
+	if d := DeltaE(color.White, color.White); d != 0 {
+		t.Errorf(errmsg, 0, d)
+	}
+
+	// black to white spans the full lightness range
+	if d := DeltaE(color.Black, color.White); math.Abs(d-100) > 0.01 {
+		t.Errorf(errmsg, 100, d)
+	}
+
+	near := DeltaE(color.RGBA{200, 0, 0, 255}, color.RGBA{201, 0, 0, 255})
+	far := DeltaE(color.RGBA{200, 0, 0, 255}, color.RGBA{0, 0, 200, 255})
+	if near >= far {
+		t.Errorf(errmsg, "near < far", near)
+	}
+}
@@ -0,0 +1,77 @@
+package palette
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Descriptor is a color expressed in a particular color space, which
+// can be compared with other colors in that space and encoded for
+// indexing.
+type Descriptor interface {
+	color.Color
+	// Distance returns the distance to o within the descriptor's space
+	Distance(o color.Color) float64
+	// Bytes returns a fixed width encoding of the descriptor
+	Bytes() []byte
+}
+
+// Space is a color space tiles can be described and compared in.
+type Space int
+
+const (
+	SpaceRGB Space = iota
+	SpaceLab
+	SpaceHSV
+	SpaceYCbCr
+)
+
+// Describe converts c in to a Descriptor for the space.
+func (s Space) Describe(c color.Color) Descriptor {
+	switch s {
+	case SpaceLab:
+		return NewLab(c)
+	case SpaceHSV:
+		return NewHSV(c)
+	case SpaceYCbCr:
+		return NewYCbCr(c)
+	}
+	return NewColorKey(c)
+}
+
+// Distance returns the distance between a and b within the space.
+func (s Space) Distance(a, b color.Color) float64 {
+	return s.Describe(a).Distance(b)
+}
+
+func (s Space) String() string {
+	switch s {
+	case SpaceLab:
+		return "lab"
+	case SpaceHSV:
+		return "hsv"
+	case SpaceYCbCr:
+		return "ycbcr"
+	}
+	return "rgb"
+}
+
+// ParseSpace returns the space named s, as named by String.
+func ParseSpace(s string) (Space, error) {
+	for _, space := range []Space{SpaceRGB, SpaceLab, SpaceHSV, SpaceYCbCr} {
+		if space.String() == s {
+			return space, nil
+		}
+	}
+	return SpaceRGB, UnknownSpace{Name: s}
+}
+
+// errors
+
+type UnknownSpace struct {
+	Name string
+}
+
+func (u UnknownSpace) Error() string {
+	return fmt.Sprintf("unknown color space %q, expected rgb, lab, hsv or ycbcr", u.Name)
+}
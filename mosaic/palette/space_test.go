@@ -0,0 +1,99 @@
+package palette
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+var spaceColors = []color.RGBA{
+	{0, 0, 0, 255},
+	{255, 255, 255, 255},
+	{255, 0, 0, 255},
+	{0, 255, 0, 255},
+	{0, 0, 255, 255},
+	{12, 200, 99, 255},
+	{250, 128, 4, 255},
+	{77, 77, 78, 255},
+}
+
+// within reports whether every 8-bit component of a and b differs by at
+// most n.
+func within(a, b color.Color, n int) bool {
+	r1, g1, b1, _ := a.RGBA()
+	r2, g2, b2, _ := b.RGBA()
+	for _, d := range []int{
+		int(r1>>8) - int(r2>>8),
+		int(g1>>8) - int(g2>>8),
+		int(b1>>8) - int(b2>>8),
+	} {
+		if d > n || d < -n {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpace_RoundTrip(t *testing.T) {
+	for _, s := range []Space{SpaceRGB, SpaceLab, SpaceHSV, SpaceYCbCr} {
+		for _, c := range spaceColors {
+			d := s.Describe(c)
+			if !within(d, c, 1) {
+				t.Errorf("%s "+errmsg, s, c, color.RGBAModel.Convert(d))
+			}
+			if dist := d.Distance(c); dist > 1 {
+				t.Errorf("%s "+errmsg, s, 0, dist)
+			}
+		}
+	}
+}
+
+func TestSpace_Conversions(t *testing.T) {
+	for _, c := range spaceColors {
+		// convert between spaces without passing through RGB explicitly
+		lab := NewLab(NewHSV(NewYCbCr(c)))
+		if !within(lab, c, 2) {
+			t.Errorf(errmsg, c, color.RGBAModel.Convert(lab))
+		}
+	}
+
+	red := NewHSV(color.RGBA{255, 0, 0, 255})
+	if red != (HSV{H: 0, S: 1, V: 1}) {
+		t.Errorf(errmsg, HSV{H: 0, S: 1, V: 1}, red)
+	}
+
+	blue := NewHSV(color.RGBA{0, 0, 255, 255})
+	if math.Abs(blue.H-240) > 1e-9 {
+		t.Errorf(errmsg, 240, blue.H)
+	}
+
+	white := NewLab(color.White)
+	if math.Abs(white.L-100) > 0.05 || math.Abs(white.A) > 0.05 || math.Abs(white.B) > 0.05 {
+		t.Errorf(errmsg, Lab{L: 100}, white)
+	}
+}
+
+func TestSpace_Bytes(t *testing.T) {
+	dark, light := NewLab(color.RGBA{20, 20, 20, 255}), NewLab(color.RGBA{200, 200, 200, 255})
+	if string(dark.Bytes()) >= string(light.Bytes()) {
+		t.Errorf(errmsg, "dark < light", dark.Bytes())
+	}
+
+	for _, s := range []Space{SpaceRGB, SpaceLab, SpaceHSV, SpaceYCbCr} {
+		a, b := s.Describe(spaceColors[0]).Bytes(), s.Describe(spaceColors[1]).Bytes()
+		if len(a) != len(b) || len(a) == 0 {
+			t.Errorf("%s "+errmsg, s, len(a), len(b))
+		}
+	}
+}
+
+func TestParseSpace(t *testing.T) {
+	for _, s := range []Space{SpaceRGB, SpaceLab, SpaceHSV, SpaceYCbCr} {
+		if got, err := ParseSpace(s.String()); err != nil || got != s {
+			t.Errorf(errmsg, s, got)
+		}
+	}
+	if _, err := ParseSpace("cmyk"); err != (UnknownSpace{Name: "cmyk"}) {
+		t.Errorf(errmsg, UnknownSpace{Name: "cmyk"}, err)
+	}
+}
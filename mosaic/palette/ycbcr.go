@@ -0,0 +1,35 @@
+package palette
+
+import (
+	"image/color"
+	"math"
+)
+
+// YCbCr is a color described by its luma and chroma components, as
+// used by JPEG. It separates brightness from color, which suits
+// matching on luminance.
+type YCbCr struct {
+	color.YCbCr
+}
+
+func NewYCbCr(c color.Color) YCbCr {
+	if y, ok := c.(YCbCr); ok {
+		return y
+	}
+	return YCbCr{YCbCr: color.YCbCrModel.Convert(c).(color.YCbCr)}
+}
+
+// Distance returns the euclidean distance between y and o in YCbCr
+// space, ranging from 0 to roughly 441.
+func (y YCbCr) Distance(o color.Color) float64 {
+	p := NewYCbCr(o)
+	dy := float64(y.Y) - float64(p.Y)
+	db := float64(y.Cb) - float64(p.Cb)
+	dr := float64(y.Cr) - float64(p.Cr)
+	return math.Sqrt(dy*dy + db*db + dr*dr)
+}
+
+// Bytes encodes y as 3 bytes, sortable by luma then chroma.
+func (y YCbCr) Bytes() []byte {
+	return []byte{y.Y, y.Cb, y.Cr}
+}
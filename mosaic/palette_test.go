@@ -0,0 +1,79 @@
+package mosaic
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+func TestTilePalette_ConvertRotates(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	a, b := NewImageTile(fill(red, 4)), NewImageTile(fill(red, 4))
+	p := NewTilePalette([]palette.Tile{a, b}, 4)
+
+	key := palette.NewColorKey(red)
+	for i, expected := range []palette.Tile{a, b, a} {
+		if tile := p.Convert(key); tile != expected {
+			t.Errorf("[%d] "+errmsg, i, expected, tile)
+		}
+	}
+
+	// candidates are unaffected by rotation
+	if c := p.Candidates(key); len(c) != 2 || c[0] != palette.Tile(a) {
+		t.Errorf(errmsg, []palette.Tile{a, b}, c)
+	}
+}
+
+func TestTilePalette_ConvertNearest(t *testing.T) {
+	red := NewImageTile(fill(color.RGBA{255, 0, 0, 255}, 4))
+	blue := NewImageTile(fill(color.RGBA{0, 0, 255, 255}, 4))
+	p := NewTilePalette([]palette.Tile{red, blue}, 4)
+
+	if tile := p.Convert(palette.NewColorKey(color.RGBA{200, 10, 40, 255})); tile != palette.Tile(red) {
+		t.Errorf(errmsg, red, tile)
+	}
+	if tile := p.Convert(palette.NewColorKey(color.RGBA{20, 10, 180, 255})); tile != palette.Tile(blue) {
+		t.Errorf(errmsg, blue, tile)
+	}
+}
+
+func TestConverter_DecodeColorSpace(t *testing.T) {
+	// to grey, green is nearer in RGB but red is perceptually nearer
+	tints := tilePalette([]palette.Tile{
+		NewImageTile(fill(color.RGBA{0x99, 0xcc, 0x99, 255}, 4)),
+		NewImageTile(fill(color.RGBA{0x99, 0x66, 0x66, 255}, 4)),
+	})
+	im := fill(color.RGBA{0x99, 0x99, 0x99, 255}, 8)
+
+	for space, expected := range map[palette.Space]string{palette.SpaceRGB: "#99cc99", palette.SpaceLab: "#996666"} {
+		d := NewConverter(im, "", WithWidth(1), WithHeight(1), WithSize(4),
+			WithPaletteGenerator(tints), WithColorSpace(space))
+		if _, err := d.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Manifest()[0].Color; got != expected {
+			t.Errorf("%s: "+errmsg, space, expected, got)
+		}
+	}
+}
+
+func TestTilePalette_ConvertEmpty(t *testing.T) {
+	p := NewTilePalette(nil, 4)
+	if tile := p.Convert(palette.NewColorKey(color.Black)); tile != nil {
+		t.Errorf(errmsg, nil, tile)
+	}
+}
+
+func TestNewUniformWebColorPalette(t *testing.T) {
+	p, err := NewUniformWebColorPalette("", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range WebSafe {
+		if tile := p.Convert(palette.NewColorKey(c)); palette.Distance(tile, c) != 0 {
+			t.Errorf(errmsg, c, tile)
+		}
+	}
+}
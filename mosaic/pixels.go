@@ -0,0 +1,116 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+)
+
+// bins counts pixels by their nearest web safe color, indexed in the
+// same order as WebSafe, along with the sum of every pixel for their
+// mean.
+type bins struct {
+	n     [216]int
+	sum   [3]uint64
+	total int
+}
+
+// webSafeStep is the distance between web safe levels in 16-bit color.
+const webSafeStep = 0x3333
+
+// level returns the nearest web safe level (0 to 5) of a 16-bit
+// component. Web safe colors form a regular grid, so this is equivalent
+// to WebSafe.Convert but needs no search.
+func level(v uint32) int {
+	return int((v + webSafeStep/2) / webSafeStep)
+}
+
+func (b *bins) add(r, g, bl uint32) {
+	b.n[level(r)*36+level(g)*6+level(bl)]++
+	b.sum[0] += uint64(r)
+	b.sum[1] += uint64(g)
+	b.sum[2] += uint64(bl)
+	b.total++
+}
+
+// max returns the most common color, breaking ties on the lowest index,
+// or nil when nothing has been counted.
+func (b *bins) max() color.Color {
+	idx, max := -1, 0
+	for i, v := range b.n {
+		if v > max {
+			idx, max = i, v
+		}
+	}
+	if idx < 0 {
+		return nil
+	}
+	return WebSafe[idx]
+}
+
+// mean returns the mean color, or nil when nothing has been counted.
+func (b *bins) mean() color.Color {
+	if b.total == 0 {
+		return nil
+	}
+	n := uint64(b.total)
+	return color.RGBA64{
+		R: uint16((b.sum[0] + n/2) / n),
+		G: uint16((b.sum[1] + n/2) / n),
+		B: uint16((b.sum[2] + n/2) / n),
+		A: 0xffff,
+	}
+}
+
+// count bins every pixel of im within r. Common image types have their
+// pixel buffers read directly, avoiding the allocation of a color.Color
+// per pixel which image.Image.At incurs.
+func (b *bins) count(im image.Image, r image.Rectangle) {
+	in := r.Intersect(im.Bounds())
+	// pixels outside the image are transparent black
+	if out := r.Dx()*r.Dy() - in.Dx()*in.Dy(); r.Dx() > 0 && r.Dy() > 0 && out > 0 {
+		b.n[0] += out
+		b.total += out
+	}
+
+	switch im := im.(type) {
+	case *image.RGBA:
+		for y := in.Min.Y; y < in.Max.Y; y++ {
+			i := im.PixOffset(in.Min.X, y)
+			for x := in.Min.X; x < in.Max.X; x, i = x+1, i+4 {
+				b.add(uint32(im.Pix[i])*0x101, uint32(im.Pix[i+1])*0x101, uint32(im.Pix[i+2])*0x101)
+			}
+		}
+	case *image.NRGBA:
+		for y := in.Min.Y; y < in.Max.Y; y++ {
+			i := im.PixOffset(in.Min.X, y)
+			for x := in.Min.X; x < in.Max.X; x, i = x+1, i+4 {
+				a := uint32(im.Pix[i+3]) * 0x101
+				b.add(uint32(im.Pix[i])*0x101*a/0xffff, uint32(im.Pix[i+1])*0x101*a/0xffff, uint32(im.Pix[i+2])*0x101*a/0xffff)
+			}
+		}
+	case *image.YCbCr:
+		for y := in.Min.Y; y < in.Max.Y; y++ {
+			for x := in.Min.X; x < in.Max.X; x++ {
+				// converted at the full 16-bit precision of At
+				yi, ci := im.YOffset(x, y), im.COffset(x, y)
+				r, g, bl, _ := color.YCbCr{Y: im.Y[yi], Cb: im.Cb[ci], Cr: im.Cr[ci]}.RGBA()
+				b.add(r, g, bl)
+			}
+		}
+	case *image.Gray:
+		for y := in.Min.Y; y < in.Max.Y; y++ {
+			i := im.PixOffset(in.Min.X, y)
+			for x := in.Min.X; x < in.Max.X; x, i = x+1, i+1 {
+				v := uint32(im.Pix[i]) * 0x101
+				b.add(v, v, v)
+			}
+		}
+	default:
+		for y := in.Min.Y; y < in.Max.Y; y++ {
+			for x := in.Min.X; x < in.Max.X; x++ {
+				r, g, bl, _ := im.At(x, y).RGBA()
+				b.add(r, g, bl)
+			}
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package mosaic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// units are the lengths understood by ParseLength, in inches.
+var units = map[string]float64{
+	"in": 1,
+	"cm": 1 / 2.54,
+	"mm": 1 / 25.4,
+}
+
+// ParseLength parses a physical length such as "3mm", "29.7cm" or
+// "11in", returning it in inches.
+func ParseLength(s string) (float64, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	for unit, scale := range units {
+		if !strings.HasSuffix(s, unit) {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, unit)), 64)
+		if err != nil || v < 0 {
+			break
+		}
+		return v * scale, nil
+	}
+	return 0, fmt.Errorf("invalid length %q, expected a number followed by in, cm or mm", s)
+}
+
+// PrintSize is the physical size of a print in inches along with the
+// resolution it is printed at.
+type PrintSize struct {
+	Width, Height float64
+	DPI           float64
+}
+
+// ParsePrintSize parses dimensions such as "60x90cm" or "24x36in".
+func ParsePrintSize(s string, dpi float64) (PrintSize, error) {
+	dims := strings.SplitN(strings.ToLower(s), "x", 2)
+	if len(dims) != 2 {
+		return PrintSize{}, fmt.Errorf("invalid print size %q, expected WxH followed by in, cm or mm", s)
+	}
+
+	// the unit may only be given once, after the height
+	unit := strings.TrimLeft(dims[1], "0123456789. ")
+	if _, err := strconv.ParseFloat(strings.TrimSpace(dims[0]), 64); err == nil {
+		dims[0] += unit
+	}
+
+	w, err := ParseLength(dims[0])
+	if err != nil {
+		return PrintSize{}, err
+	}
+	h, err := ParseLength(dims[1])
+	if err != nil {
+		return PrintSize{}, err
+	}
+	if w == 0 || h == 0 || dpi <= 0 {
+		return PrintSize{}, fmt.Errorf("invalid print size %q at %g dpi", s, dpi)
+	}
+	return PrintSize{Width: w, Height: h, DPI: dpi}, nil
+}
+
+// Pixels returns the size of the print in pixels.
+func (p PrintSize) Pixels() image.Point {
+	return image.Pt(p.Inches(p.Width), p.Inches(p.Height))
+}
+
+// Inches returns the number of pixels spanning a length in inches.
+func (p PrintSize) Inches(v float64) int {
+	return int(math.Floor(v*p.DPI + 0.5))
+}
+
+// Grid derives the tile size and number of rows needed to fill the print
+// with across tiles per row. The grid may fall short of the print by
+// less than a tile, see Fit, but never exceeds it.
+func (p PrintSize) Grid(across int) (width, height, size int) {
+	px := p.Pixels()
+	if across < 1 {
+		across = 1
+	}
+	size = px.X / across
+	if size < 1 {
+		size = 1
+	}
+	height = px.Y / size
+	if height < 1 {
+		height = 1
+	}
+	return across, height, size
+}
+
+// Fit centres im on a white print, padding it out to exactly the size of
+// the print. Resampling would blur the edges of tiles, so an image
+// larger than the print is cropped instead.
+func (p PrintSize) Fit(im image.Image) *image.RGBA {
+	px := p.Pixels()
+	dst := image.NewRGBA(image.Rectangle{Max: px})
+	draw.Draw(dst, dst.Bounds(), image.White, image.ZP, draw.Src)
+
+	b := im.Bounds()
+	r := image.Rectangle{Max: b.Size()}.Add(px.Sub(b.Size()).Div(2))
+	draw.Draw(dst, r, im, b.Min, draw.Src)
+	return dst
+}
+
+// FitBleed centres im on the print extended by bleed pixels on every
+// side, as Fit does, but repeats the edge pixels of im out to the edges
+// rather than padding with white, so neither a grid falling short of the
+// print nor trimming within the bleed leaves a white border.
+func (p PrintSize) FitBleed(im image.Image, bleed int) *image.RGBA {
+	px := p.Pixels().Add(image.Pt(2*bleed, 2*bleed))
+	return extend(im, px, px.Sub(im.Bounds().Size()).Div(2))
+}
+
+// CropAspect crops the centre of im to the aspect ratio w:h, such as
+// that of a grid of square tiles filling a print.
+func CropAspect(im image.Image, w, h int) *image.RGBA {
+	r := fitAspect(im.Bounds(), image.Pt(w, h))
+	dst := image.NewRGBA(image.Rectangle{Max: r.Size()})
+	draw.Draw(dst, dst.Bounds(), im, r.Min, draw.Src)
+	return dst
+}
+
+// Bleed extends im by bleed pixels on every side, repeating its edge
+// pixels, so the print can be trimmed without leaving a white border.
+func Bleed(im image.Image, bleed int) *image.RGBA {
+	return extend(im, im.Bounds().Size().Add(image.Pt(2*bleed, 2*bleed)), image.Pt(bleed, bleed))
+}
+
+// extend draws im at offset off in to a new image of the given size,
+// repeating the edge pixels of im out to the edges of the new image.
+func extend(im image.Image, size, off image.Point) *image.RGBA {
+	b := im.Bounds()
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	for y := 0; y < size.Y; y++ {
+		sy := clampInt(y-off.Y, 0, b.Dy()-1) + b.Min.Y
+		for x := 0; x < size.X; x++ {
+			sx := clampInt(x-off.X, 0, b.Dx()-1) + b.Min.X
+			dst.Set(x, y, im.At(sx, sy))
+		}
+	}
+	return dst
+}
+
+// CropMarks surrounds im with a white margin of margin pixels and draws
+// crop marks within it, in line with the trim edges which lie inset by
+// bleed pixels from the edges of im.
+func CropMarks(im image.Image, bleed, margin int) *image.RGBA {
+	b := im.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()+2*margin, b.Dy()+2*margin))
+	draw.Draw(dst, dst.Bounds(), image.White, image.ZP, draw.Src)
+	draw.Draw(dst, b.Sub(b.Min).Add(image.Pt(margin, margin)), im, b.Min, draw.Src)
+
+	// marks stop short of the bleed so they never touch the artwork
+	var (
+		mark  = image.NewUniform(color.Black)
+		width = margin/50 + 1
+		gap   = bleed + margin/4
+		trim  = image.Rect(margin+bleed, margin+bleed, margin+b.Dx()-bleed, margin+b.Dy()-bleed)
+		max   = dst.Bounds().Max
+	)
+	for _, x := range []int{trim.Min.X, trim.Max.X - width} {
+		draw.Draw(dst, image.Rect(x, 0, x+width, trim.Min.Y-gap), mark, image.ZP, draw.Src)
+		draw.Draw(dst, image.Rect(x, trim.Max.Y+gap, x+width, max.Y), mark, image.ZP, draw.Src)
+	}
+	for _, y := range []int{trim.Min.Y, trim.Max.Y - width} {
+		draw.Draw(dst, image.Rect(0, y, trim.Min.X-gap, y+width), mark, image.ZP, draw.Src)
+		draw.Draw(dst, image.Rect(trim.Max.X+gap, y, max.X, y+width), mark, image.ZP, draw.Src)
+	}
+	return dst
+}
+
+// EncodePNG writes im as a PNG with a pHYs chunk recording dpi, so it
+// prints at its intended physical size.
+func EncodePNG(w io.Writer, im image.Image, dpi float64) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, im); err != nil {
+		return err
+	}
+
+	// the pHYs chunk must precede the image data, so it follows IHDR
+	// which is always first: 8 byte signature + 25 byte chunk
+	data := buf.Bytes()
+	const ihdrEnd = 8 + 25
+
+	ppm := uint32(math.Floor(dpi/0.0254 + 0.5))
+	body := make([]byte, 9)
+	binary.BigEndian.PutUint32(body[0:], ppm)
+	binary.BigEndian.PutUint32(body[4:], ppm)
+	// unit is the metre
+	body[8] = 1
+
+	if _, err := w.Write(data[:ihdrEnd]); err != nil {
+		return err
+	}
+	if err := writeChunk(w, "pHYs", body); err != nil {
+		return err
+	}
+	_, err := w.Write(data[ihdrEnd:])
+	return err
+}
+
+func writeChunk(w io.Writer, typ string, body []byte) error {
+	chunk := make([]byte, 8, 12+len(body))
+	binary.BigEndian.PutUint32(chunk[0:], uint32(len(body)))
+	copy(chunk[4:], typ)
+	chunk = append(chunk, body...)
+
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(chunk[4:]))
+	_, err := w.Write(append(chunk, crc...))
+	return err
+}
+
+// EncodeJPEG writes im as a JPEG with a JFIF header recording dpi.
+func EncodeJPEG(w io.Writer, im image.Image, dpi float64, o *jpeg.Options) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, im, o); err != nil {
+		return err
+	}
+
+	data := buf.Bytes()
+	// drop any APP0 segment the encoder wrote after the SOI marker
+	rest := data[2:]
+	if len(rest) > 4 && rest[0] == 0xff && rest[1] == 0xe0 {
+		rest = rest[2+int(binary.BigEndian.Uint16(rest[2:])):]
+	}
+
+	d := uint16(math.Min(math.Floor(dpi+0.5), math.MaxUint16))
+	app0 := []byte{
+		0xff, 0xe0, 0, 16,
+		'J', 'F', 'I', 'F', 0,
+		1, 2,
+		// unit is the inch
+		1,
+		byte(d >> 8), byte(d), byte(d >> 8), byte(d),
+		0, 0,
+	}
+
+	if _, err := w.Write(data[:2]); err != nil {
+		return err
+	}
+	if _, err := w.Write(app0); err != nil {
+		return err
+	}
+	_, err := w.Write(rest)
+	return err
+}
+
+func clampInt(v, min, max int) int {
+	switch {
+	case v < min:
+		return min
+	case v > max:
+		return max
+	}
+	return v
+}
@@ -0,0 +1,200 @@
+package mosaic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestParsePrintSize(t *testing.T) {
+	for s, expected := range map[string]PrintSize{
+		"24x36in":     {Width: 24, Height: 36, DPI: 300},
+		"25.4x50.8cm": {Width: 10, Height: 20, DPI: 300},
+		"254mm x 1in": {Width: 10, Height: 1, DPI: 300},
+	} {
+		p, err := ParsePrintSize(s, 300)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(p.Width-expected.Width) > 1e-9 || math.Abs(p.Height-expected.Height) > 1e-9 || p.DPI != expected.DPI {
+			t.Errorf(errmsg, expected, p)
+		}
+	}
+
+	for _, s := range []string{"24", "24x36", "24x36ft", "0x1in", "-1x1in"} {
+		if _, err := ParsePrintSize(s, 300); err == nil {
+			t.Errorf("%s: "+errmsg, s, "error", err)
+		}
+	}
+}
+
+func TestPrintSizeGrid(t *testing.T) {
+	p := PrintSize{Width: 10, Height: 15, DPI: 300}
+	if px := p.Pixels(); px != image.Pt(3000, 4500) {
+		t.Errorf(errmsg, image.Pt(3000, 4500), px)
+	}
+
+	if w, h, size := p.Grid(40); w != 40 || h != 60 || size != 75 {
+		t.Errorf(errmsg, "40 60 75", []int{w, h, size})
+	}
+
+	// a grid falling short of the print is padded, not stretched
+	if w, h, size := p.Grid(41); w != 41 || h != 61 || size != 73 {
+		t.Errorf(errmsg, "41 61 73", []int{w, h, size})
+	}
+
+	mosaic := fill(color.RGBA{255, 0, 0, 255}, 2992)
+	im := p.Fit(mosaic)
+	if size := im.Bounds().Size(); size != image.Pt(3000, 4500) {
+		t.Errorf(errmsg, image.Pt(3000, 4500), size)
+	}
+	for _, tc := range []struct {
+		pt image.Point
+		c  color.RGBA
+	}{
+		{image.Pt(3, 10), color.RGBA{255, 255, 255, 255}},
+		{image.Pt(4, 754), color.RGBA{255, 0, 0, 255}},
+		{image.Pt(2995, 3745), color.RGBA{255, 0, 0, 255}},
+		{image.Pt(2996, 3746), color.RGBA{255, 255, 255, 255}},
+	} {
+		if c := im.At(tc.pt.X, tc.pt.Y); c != tc.c {
+			t.Errorf("%v: "+errmsg, tc.pt, tc.c, c)
+		}
+	}
+}
+
+func TestCropAspect(t *testing.T) {
+	im := fill(color.RGBA{255, 0, 0, 255}, 30)
+	im.Set(15, 0, color.RGBA{0, 0, 255, 255})
+
+	// 2:3 keeps the full height, centred
+	crop := CropAspect(im, 2, 3)
+	if b := crop.Bounds(); b != image.Rect(0, 0, 20, 30) {
+		t.Errorf(errmsg, image.Rect(0, 0, 20, 30), b)
+	}
+	if c := crop.At(10, 0); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf(errmsg, color.RGBA{0, 0, 255, 255}, c)
+	}
+
+	if b := CropAspect(im, 3, 1).Bounds(); b != image.Rect(0, 0, 30, 10) {
+		t.Errorf(errmsg, image.Rect(0, 0, 30, 10), b)
+	}
+}
+
+func TestBleedAndCropMarks(t *testing.T) {
+	im := fill(color.RGBA{255, 0, 0, 255}, 20)
+	im.Set(0, 0, color.RGBA{0, 0, 255, 255})
+
+	bled := Bleed(im, 5)
+	if size := bled.Bounds().Size(); size != image.Pt(30, 30) {
+		t.Fatalf(errmsg, image.Pt(30, 30), size)
+	}
+	// the corner pixel is repeated out to the corner of the bleed
+	if c := bled.At(0, 0); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf(errmsg, color.RGBA{0, 0, 255, 255}, c)
+	}
+	if c := bled.At(29, 0); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf(errmsg, color.RGBA{255, 0, 0, 255}, c)
+	}
+
+	marked := CropMarks(bled, 5, 20)
+	if size := marked.Bounds().Size(); size != image.Pt(70, 70) {
+		t.Fatalf(errmsg, image.Pt(70, 70), size)
+	}
+
+	black, white := color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+	for _, tc := range []struct {
+		x, y int
+		c    color.RGBA
+	}{
+		// marks in line with the trim edge at x = 25
+		{25, 0, black},
+		{25, 10, black},
+		// clear of the bleed
+		{25, 19, white},
+		{0, 0, white},
+		// artwork
+		{20, 20, color.RGBA{0, 0, 255, 255}},
+		{49, 49, color.RGBA{255, 0, 0, 255}},
+	} {
+		if c := marked.At(tc.x, tc.y); c != tc.c {
+			t.Errorf("(%d, %d): "+errmsg, tc.x, tc.y, tc.c, c)
+		}
+	}
+}
+
+func TestPrintSizeFitBleed(t *testing.T) {
+	// a mosaic falling short of the print, as with Fit
+	p := PrintSize{Width: 10, Height: 15, DPI: 300}
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	mosaic := fill(red, 2992)
+	mosaic.Set(0, 0, blue)
+
+	im := p.FitBleed(mosaic, 5)
+	if size := im.Bounds().Size(); size != image.Pt(3010, 4510) {
+		t.Fatalf(errmsg, image.Pt(3010, 4510), size)
+	}
+	for _, tc := range []struct {
+		pt image.Point
+		c  color.RGBA
+	}{
+		// the bleed repeats the edges of the artwork, never white paper
+		{image.Pt(0, 0), blue},
+		{image.Pt(9, 759), blue},
+		{image.Pt(3009, 0), red},
+		{image.Pt(0, 4509), red},
+		{image.Pt(1505, 4509), red},
+		// the artwork is centred as with Fit
+		{image.Pt(10, 760), red},
+	} {
+		if c := im.At(tc.pt.X, tc.pt.Y); c != tc.c {
+			t.Errorf("%v: "+errmsg, tc.pt, tc.c, c)
+		}
+	}
+}
+
+func TestEncodePNG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := EncodePNG(buf, gradient(10, 10), 300); err != nil {
+		t.Fatal(err)
+	}
+
+	// the image still decodes
+	if _, err := png.Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	i := bytes.Index(data, []byte("pHYs"))
+	if i < 0 || i > bytes.Index(data, []byte("IDAT")) {
+		t.Fatalf(errmsg, "pHYs before IDAT", i)
+	}
+	// 300 dpi is 11811 pixels per metre
+	if ppm := binary.BigEndian.Uint32(data[i+4:]); ppm != 11811 || data[i+12] != 1 {
+		t.Errorf(errmsg, 11811, ppm)
+	}
+}
+
+func TestEncodeJPEG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := EncodeJPEG(buf, gradient(10, 10), 300, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := jpeg.Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.Equal(data[:4], []byte{0xff, 0xd8, 0xff, 0xe0}) || string(data[6:11]) != "JFIF\x00" {
+		t.Fatalf(errmsg, "JFIF header", data[:11])
+	}
+	if unit, x, y := data[13], binary.BigEndian.Uint16(data[14:]), binary.BigEndian.Uint16(data[16:]); unit != 1 || x != 300 || y != 300 {
+		t.Errorf(errmsg, "1 300 300", []uint16{uint16(unit), x, y})
+	}
+}
@@ -0,0 +1,163 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"sort"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// Quantiser reduces the colors of an image down to at most n.
+type Quantiser func(im image.Image, n int) color.Palette
+
+// NewQuantisedPalette returns a palette.Generator of flat color tiles
+// whose colors are derived from im by q, so a flat color mosaic can
+// match the source artwork.
+func NewQuantisedPalette(im image.Image, n int, q Quantiser) palette.Generator {
+	return palette.GeneratorFunc(func(_ string, size int) (palette.Palette, error) {
+		p := q(im, n)
+		if len(p) == 0 {
+			return nil, EmptyPalette{}
+		}
+		return NewUniformPalette(p, size), nil
+	})
+}
+
+// maxSamples bounds the number of pixels quantisation considers.
+const maxSamples = 1 << 16
+
+// pixels samples up to maxSamples pixels evenly from im as 8-bit RGB.
+func pixels(im image.Image) [][3]uint8 {
+	bounds := im.Bounds()
+	step := 1
+	for (bounds.Dx()/step)*(bounds.Dy()/step) > maxSamples {
+		step++
+	}
+
+	px := make([][3]uint8, 0, (bounds.Dx()/step+1)*(bounds.Dy()/step+1))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, _ := im.At(x, y).RGBA()
+			px = append(px, [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)})
+		}
+	}
+	return px
+}
+
+// MedianCut is a Quantiser which repeatedly splits the box of colors
+// with the widest channel range at its median, then averages each box.
+func MedianCut(im image.Image, n int) color.Palette {
+	px := pixels(im)
+	if len(px) == 0 || n < 1 {
+		return nil
+	}
+
+	boxes := [][][3]uint8{px}
+	for len(boxes) < n {
+		// find the box with the widest range on any channel
+		bi, ch, widest := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				if r := channelRange(box, c); r > widest {
+					bi, ch, widest = i, c, r
+				}
+			}
+		}
+		if bi < 0 {
+			break
+		}
+
+		box := boxes[bi]
+		sort.Sort(byChannel{box, ch})
+		mid := len(box) / 2
+		boxes[bi] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	p := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		p = append(p, mean(box))
+	}
+	return p
+}
+
+// KMeans is a Quantiser which refines the colors found by MedianCut
+// using k-means clustering.
+func KMeans(im image.Image, n int) color.Palette {
+	const iterations = 10
+
+	px := pixels(im)
+	centres := MedianCut(im, n)
+	if len(centres) == 0 {
+		return nil
+	}
+
+	assign := make([]int, len(px))
+	for it := 0; it < iterations; it++ {
+		changed := false
+		for i, p := range px {
+			best, min := 0, -1
+			for j, c := range centres {
+				cr, cg, cb, _ := c.RGBA()
+				dr, dg, db := int(p[0])-int(cr>>8), int(p[1])-int(cg>>8), int(p[2])-int(cb>>8)
+				if d := dr*dr + dg*dg + db*db; min < 0 || d < min {
+					best, min = j, d
+				}
+			}
+			if assign[i] != best {
+				assign[i], changed = best, true
+			}
+		}
+		if !changed && it > 0 {
+			break
+		}
+
+		clusters := make([][][3]uint8, len(centres))
+		for i, p := range px {
+			clusters[assign[i]] = append(clusters[assign[i]], p)
+		}
+		for j, cluster := range clusters {
+			// keep the previous centre for empty clusters
+			if len(cluster) > 0 {
+				centres[j] = mean(cluster)
+			}
+		}
+	}
+	return centres
+}
+
+func channelRange(box [][3]uint8, c int) int {
+	min, max := 255, 0
+	for _, p := range box {
+		v := int(p[c])
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	return max - min
+}
+
+func mean(box [][3]uint8) color.Color {
+	var r, g, b int
+	for _, p := range box {
+		r, g, b = r+int(p[0]), g+int(p[1]), b+int(p[2])
+	}
+	n := len(box)
+	return color.RGBA{uint8((r + n/2) / n), uint8((g + n/2) / n), uint8((b + n/2) / n), 255}
+}
+
+type byChannel struct {
+	box [][3]uint8
+	c   int
+}
+
+func (b byChannel) Len() int           { return len(b.box) }
+func (b byChannel) Less(i, j int) bool { return b.box[i][b.c] < b.box[j][b.c] }
+func (b byChannel) Swap(i, j int)      { b.box[i], b.box[j] = b.box[j], b.box[i] }
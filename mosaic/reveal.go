@@ -0,0 +1,225 @@
+package mosaic
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"time"
+
+	"github.com/bamiaux/rez"
+)
+
+// Easing maps progress through an animation, from 0 to 1, on to how far
+// the animated effect has got.
+type Easing func(t float64) float64
+
+var (
+	Linear    Easing = func(t float64) float64 { return t }
+	EaseIn    Easing = func(t float64) float64 { return t * t * t }
+	EaseOut   Easing = func(t float64) float64 { return 1 - math.Pow(1-t, 3) }
+	EaseInOut Easing = func(t float64) float64 {
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		return 1 - math.Pow(-2*t+2, 3)/2
+	}
+)
+
+// Easings are the easing functions available by name.
+var Easings = map[string]Easing{
+	"linear":      Linear,
+	"ease-in":     EaseIn,
+	"ease-out":    EaseOut,
+	"ease-in-out": EaseInOut,
+}
+
+// Reveal describes the timing and size of a reveal animation.
+type Reveal struct {
+	Duration time.Duration
+	// FPS defaults to 15
+	FPS int
+	// Hold keeps the final frame on screen for longer
+	Hold time.Duration
+	// Size of each frame, defaults to the size of the mosaic. Views are
+	// cropped to its aspect ratio rather than stretched.
+	Size   image.Point
+	Easing Easing
+}
+
+// frames returns the number of frames, size of each frame, and delay
+// between them in hundredths of a second.
+func (r Reveal) frames(bounds image.Rectangle) (n int, size image.Point, delay int) {
+	fps := r.FPS
+	if fps < 1 {
+		fps = 15
+	}
+	n = int(r.Duration.Seconds()*float64(fps) + 0.5)
+	if n < 2 {
+		n = 2
+	}
+
+	size = r.Size
+	if size.X < 1 || size.Y < 1 {
+		size = bounds.Size()
+	}
+
+	delay = int(math.Floor(100/float64(fps) + 0.5))
+	if delay < 1 {
+		delay = 1
+	}
+	return
+}
+
+// progress returns how far through the effect frame i of n is.
+func (r Reveal) progress(i, n int) float64 {
+	ease := r.Easing
+	if ease == nil {
+		ease = Linear
+	}
+	return ease(float64(i) / float64(n-1))
+}
+
+// animation builds an animation of n frames rendered by frame.
+func (r Reveal) animation(n, delay int, frame func(i int) (image.Image, error)) (*Animation, error) {
+	a := &Animation{}
+	for i := 0; i < n; i++ {
+		im, err := frame(i)
+		if err != nil {
+			return nil, err
+		}
+		a.Frames = append(a.Frames, im)
+		a.Delays = append(a.Delays, delay)
+	}
+	a.Delays[n-1] += int(r.Hold.Seconds() * 100)
+	return a, nil
+}
+
+// Zoom animates zooming out from the region focus of mosaic, typically
+// the Rect of a single Placement, until the whole mosaic is in view.
+// The zoom is geometric so its apparent speed stays constant. Every view
+// shares the aspect ratio of the frames, so focus is grown to it.
+func (r Reveal) Zoom(mosaic image.Image, focus image.Rectangle) (*Animation, error) {
+	// work relative to the origin, as src is
+	bounds := mosaic.Bounds()
+	focus = focus.Intersect(bounds).Sub(bounds.Min)
+	bounds = bounds.Sub(bounds.Min)
+
+	n, size, delay := r.frames(bounds)
+	end := fitAspect(bounds, size)
+	if focus.Empty() {
+		focus = end
+	}
+	focus = growAspect(focus, end, size)
+
+	src := rgba(mosaic)
+	return r.animation(n, delay, func(i int) (image.Image, error) {
+		t := r.progress(i, n)
+		view := image.Rectangle{
+			Min: image.Pt(
+				zoom(focus.Min.X, focus.Dx(), end.Min.X, end.Dx(), t),
+				zoom(focus.Min.Y, focus.Dy(), end.Min.Y, end.Dy(), t),
+			),
+		}
+		view.Max = view.Min.Add(image.Pt(
+			int(math.Floor(geometric(focus.Dx(), end.Dx(), t)+0.5)),
+			int(math.Floor(geometric(focus.Dy(), end.Dy(), t)+0.5)),
+		))
+		return scaleTo(src, view.Intersect(end), size)
+	})
+}
+
+// fitAspect returns the largest rectangle centred within r with the
+// aspect ratio of size.
+func fitAspect(r image.Rectangle, size image.Point) image.Rectangle {
+	s := r.Size()
+	if s.X*size.Y > s.Y*size.X {
+		s.X = s.Y * size.X / size.Y
+	} else {
+		s.Y = s.X * size.Y / size.X
+	}
+	min := r.Min.Add(r.Size().Sub(s).Div(2))
+	return image.Rectangle{Min: min, Max: min.Add(s)}
+}
+
+// growAspect returns the smallest rectangle centred on r with the aspect
+// ratio of size, moved to lie within bounds.
+func growAspect(r, bounds image.Rectangle, size image.Point) image.Rectangle {
+	s := r.Size()
+	if s.X*size.Y > s.Y*size.X {
+		s.Y = (s.X*size.Y + size.X - 1) / size.X
+	} else {
+		s.X = (s.Y*size.X + size.Y - 1) / size.Y
+	}
+	if s.X > bounds.Dx() || s.Y > bounds.Dy() {
+		return bounds
+	}
+
+	min := r.Min.Sub(s.Sub(r.Size()).Div(2))
+	min.X = clampInt(min.X, bounds.Min.X, bounds.Max.X-s.X)
+	min.Y = clampInt(min.Y, bounds.Min.Y, bounds.Max.Y-s.Y)
+	return image.Rectangle{Min: min, Max: min.Add(s)}
+}
+
+// geometric interpolates from a to b by t in log space.
+func geometric(a, b int, t float64) float64 {
+	return math.Exp(math.Log(float64(a))*(1-t) + math.Log(float64(b))*t)
+}
+
+// zoom returns the position along one axis of a view growing
+// geometrically from the span (from, fromLen) to (to, toLen), moving in
+// proportion to its growth.
+func zoom(from, fromLen, to, toLen int, t float64) int {
+	if toLen == fromLen {
+		return to
+	}
+	k := (geometric(fromLen, toLen, t) - float64(fromLen)) / float64(toLen-fromLen)
+	return int(math.Floor(float64(from) + float64(to-from)*k + 0.5))
+}
+
+// Crossfade animates fading from the source image to the mosaic made
+// from it. Both are cropped to the aspect ratio of the frames.
+func (r Reveal) Crossfade(source, mosaic image.Image) (*Animation, error) {
+	n, size, delay := r.frames(mosaic.Bounds())
+
+	src, dst := rgba(source), rgba(mosaic)
+	from, err := scaleTo(src, fitAspect(src.Bounds(), size), size)
+	if err != nil {
+		return nil, err
+	}
+	to, err := scaleTo(dst, fitAspect(dst.Bounds(), size), size)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.animation(n, delay, func(i int) (image.Image, error) {
+		t := r.progress(i, n)
+		dst := image.NewRGBA(from.Bounds())
+		for j := range dst.Pix {
+			dst.Pix[j] = uint8(float64(from.Pix[j])*(1-t) + float64(to.Pix[j])*t + 0.5)
+		}
+		return dst, nil
+	})
+}
+
+// rgba returns im as an RGBA anchored at the origin.
+func rgba(im image.Image) *image.RGBA {
+	if im, ok := im.(*image.RGBA); ok && im.Bounds().Min == image.ZP {
+		return im
+	}
+	dst := image.NewRGBA(image.Rectangle{Max: im.Bounds().Size()})
+	draw.Draw(dst, dst.Bounds(), im, im.Bounds().Min, draw.Src)
+	return dst
+}
+
+// scaleTo scales the region view of src to size.
+func scaleTo(src *image.RGBA, view image.Rectangle, size image.Point) (*image.RGBA, error) {
+	crop := image.NewRGBA(image.Rectangle{Max: view.Size()})
+	draw.Draw(crop, crop.Bounds(), src, view.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	if crop.Bounds().Size() == size {
+		copy(dst.Pix, crop.Pix)
+		return dst, nil
+	}
+	return dst, rez.Convert(dst, crop, rez.NewBilinearFilter())
+}
@@ -0,0 +1,142 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEasings(t *testing.T) {
+	for name, ease := range Easings {
+		if a, b := ease(0), ease(1); math.Abs(a) > 1e-9 || math.Abs(b-1) > 1e-9 {
+			t.Errorf("%s: "+errmsg, name, "0 and 1", []float64{a, b})
+		}
+		for x := 0.1; x < 1; x += 0.1 {
+			if ease(x) < ease(x-0.1) {
+				t.Errorf("%s: not monotonic at %.1f", name, x)
+			}
+		}
+	}
+}
+
+func TestReveal(t *testing.T) {
+	// a red mosaic with a single blue tile in the middle
+	mosaic := fill(color.RGBA{255, 0, 0, 255}, 100)
+	focus := image.Rect(40, 40, 50, 50)
+	for y := focus.Min.Y; y < focus.Max.Y; y++ {
+		for x := focus.Min.X; x < focus.Max.X; x++ {
+			mosaic.Set(x, y, color.RGBA{0, 0, 255, 255})
+		}
+	}
+
+	r := Reveal{Duration: time.Second, FPS: 10, Hold: time.Second, Size: image.Pt(50, 50), Easing: EaseInOut}
+	a, err := r.Zoom(mosaic, focus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.Frames) != 10 {
+		t.Fatalf(errmsg, 10, len(a.Frames))
+	}
+	if a.Delays[0] != 10 || a.Delays[9] != 110 {
+		t.Errorf(errmsg, "10 and 110", a.Delays)
+	}
+
+	// the first frame is filled by the focused tile, the last shows the
+	// whole mosaic
+	first, last := a.Frames[0], a.Frames[9]
+	if size := first.Bounds().Size(); size != image.Pt(50, 50) {
+		t.Errorf(errmsg, image.Pt(50, 50), size)
+	}
+	if c := color.RGBAModel.Convert(first.At(5, 45)); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf(errmsg, color.RGBA{0, 0, 255, 255}, c)
+	}
+	if c := color.RGBAModel.Convert(last.At(2, 2)); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf(errmsg, color.RGBA{255, 0, 0, 255}, c)
+	}
+	if c := color.RGBAModel.Convert(last.At(22, 22)); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf(errmsg, color.RGBA{0, 0, 255, 255}, c)
+	}
+
+	source := fill(color.RGBA{0, 255, 0, 255}, 40)
+	a, err = Reveal{Duration: 300 * time.Millisecond, FPS: 10, Size: image.Pt(20, 20)}.Crossfade(source, mosaic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Frames) != 3 {
+		t.Fatalf(errmsg, 3, len(a.Frames))
+	}
+	for i, expected := range []color.RGBA{{0, 255, 0, 255}, {128, 128, 0, 255}, {255, 0, 0, 255}} {
+		if c := a.Frames[i].At(2, 2); c != expected {
+			t.Errorf("frame %d: "+errmsg, i, expected, c)
+		}
+	}
+}
+
+func TestRevealAspect(t *testing.T) {
+	// a 2:1 mosaic, red but for a blue tile in the middle and a green
+	// strip down the left edge
+	red, blue, green := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}, color.RGBA{0, 255, 0, 255}
+	mosaic := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			c := red
+			switch {
+			case x < 10:
+				c = green
+			case x >= 95 && x < 105 && y >= 45 && y < 55:
+				c = blue
+			}
+			mosaic.Set(x, y, c)
+		}
+	}
+
+	r := Reveal{Duration: time.Second, FPS: 3, Size: image.Pt(40, 20)}
+	a, err := r.Zoom(mosaic, image.Rect(95, 45, 105, 55))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the square tile is widened to the frames rather than stretched
+	first, last := a.Frames[0], a.Frames[len(a.Frames)-1]
+	for _, tc := range []struct {
+		im   image.Image
+		x, y int
+		c    color.RGBA
+	}{
+		{first, 2, 10, red},
+		{first, 20, 10, blue},
+		{first, 37, 10, red},
+		// the whole mosaic, scaled down
+		{last, 0, 10, green},
+		{last, 20, 10, blue},
+	} {
+		// scaling blurs the edges, so compare the strongest channel
+		if c := color.RGBAModel.Convert(tc.im.At(tc.x, tc.y)).(color.RGBA); dominant(c) != dominant(tc.c) {
+			t.Errorf("(%d, %d): "+errmsg, tc.x, tc.y, tc.c, c)
+		}
+	}
+
+	// a square view of the mosaic is cropped from its centre
+	a, err = Reveal{Duration: time.Second, FPS: 2, Size: image.Pt(20, 20)}.Crossfade(fill(green, 40), mosaic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last = a.Frames[len(a.Frames)-1]
+	if c := color.RGBAModel.Convert(last.At(1, 10)); c != red {
+		t.Errorf(errmsg, red, c)
+	}
+}
+
+// dominant returns the index of the strongest channel of c.
+func dominant(c color.RGBA) int {
+	switch {
+	case c.R >= c.G && c.R >= c.B:
+		return 0
+	case c.G >= c.B:
+		return 1
+	}
+	return 2
+}
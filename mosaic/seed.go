@@ -0,0 +1,37 @@
+package mosaic
+
+import (
+	"image/color"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// pick selects a tile from p for the cell at index of color c. By
+// default the palette rotates through its tiles, which depends on the
+// order workers happen to process cells. In deterministic mode the
+// choice is instead derived from the seed and cell index alone.
+func (d *Converter) pick(p palette.Palette, c color.Color, index int) palette.Tile {
+	key := palette.NewColorKey(c)
+	if !d.deterministic {
+		return p.Convert(key)
+	}
+
+	cp, ok := p.(palette.Candidates)
+	if !ok {
+		return p.Convert(key)
+	}
+
+	tiles := cp.Candidates(key)
+	if len(tiles) == 0 {
+		return nil
+	}
+	return tiles[mix(uint64(d.seed), uint64(index))%uint64(len(tiles))]
+}
+
+// mix hashes seed and n together using the splitmix64 finalizer.
+func mix(seed, n uint64) uint64 {
+	z := seed + (n+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
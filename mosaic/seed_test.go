@@ -0,0 +1,50 @@
+package mosaic
+
+import "testing"
+
+func TestConverter_DecodeDeterministic(t *testing.T) {
+	tiles := tileSet(4, 4)
+	// duplicate every tile so there is a choice to make per cell
+	tiles = append(tiles, tileSet(4, 4)...)
+
+	var first Manifest
+	for _, workers := range []int{1, 3, 8} {
+		for _, rows := range []bool{false, true} {
+			d := NewConverter(gradient(64, 64), "",
+				WithWidth(16),
+				WithHeight(16),
+				WithSize(4),
+				WithSeed(42),
+				WithWorkers(workers),
+				WithRowBatching(rows),
+				WithPaletteGenerator(tilePalette(tiles)))
+			if _, err := d.Decode(); err != nil {
+				t.Fatal(err)
+			}
+
+			if first == nil {
+				first = d.Manifest()
+				continue
+			}
+
+			for i, p := range d.Manifest() {
+				if p != first[i] {
+					t.Fatalf(errmsg, first[i], p)
+				}
+			}
+		}
+	}
+}
+
+func TestMix(t *testing.T) {
+	// seeds and indices all spread choices, including a seed of 0
+	seen := map[uint64]bool{}
+	for _, seed := range []uint64{0, 1} {
+		for n := uint64(0); n < 4; n++ {
+			seen[mix(seed, n)] = true
+		}
+	}
+	if len(seen) != 8 {
+		t.Errorf(errmsg, 8, len(seen))
+	}
+}
@@ -0,0 +1,110 @@
+package mosaic
+
+import (
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// SavePalette writes t to dir as an atlas of tiles, each scaled to
+// t.Size px, so that it can be loaded with LoadPalette without
+// re-reading and re-scaling the original tile images.
+func SavePalette(dir string, t *TilePalette) error {
+	a := NewAtlas(t.Size)
+	for _, tile := range t.Tiles() {
+		if err := a.Add(tile); err != nil {
+			return err
+		}
+	}
+	return a.Save(dir)
+}
+
+// LoadPalette reads a palette written by SavePalette or packed in to
+// an atlas by gotile.
+func LoadPalette(dir string) (*AtlasPalette, error) {
+	a, err := LoadAtlas(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a.Entries) == 0 {
+		return nil, EmptyPalette{}
+	}
+
+	return NewAtlasPalette(a), nil
+}
+
+// NewSavedTilePalette is a palette.Generator loading the palette saved
+// in dir. Tiles are rescaled if size differs from the saved size.
+func NewSavedTilePalette(dir string, size int) (palette.Palette, error) {
+	p, err := LoadPalette(dir)
+	if err != nil {
+		return nil, err
+	}
+	if p.Size == size {
+		return p, nil
+	}
+
+	tiles := p.Tiles()
+	for i, tile := range tiles {
+		im, err := scaleTile(tile, size)
+		if err != nil {
+			return nil, err
+		}
+		t := tile.(*ImageTile)
+		tiles[i] = &ImageTile{Image: im, Color: t.Color, Source: t.Source}
+	}
+	return NewTilePalette(tiles, size), nil
+}
+
+// IsSavedPalette reports whether dir contains a saved palette.
+func IsSavedPalette(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, PaletteManifest))
+	return err == nil
+}
+
+// scaleTile returns the tile as a size by size image.
+func scaleTile(tile image.Image, size int) (image.Image, error) {
+	bounds := tile.Bounds()
+	switch {
+	case bounds.Dx() == size && bounds.Dy() == size:
+		return tile, nil
+	case bounds.Dx() >= size && bounds.Dy() >= size && bounds.Dx() < 1<<20:
+		// rez expects images anchored at the origin
+		src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(src, src.Bounds(), tile, bounds.Min, draw.Src)
+		return Resize(src, size, size)
+	}
+
+	// too small to scale down (or unbounded, e.g. uniform), so the tile
+	// is drawn as is
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), tile, bounds.Min, draw.Src)
+	return dst, nil
+}
+
+func readImage(path string) (image.Image, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+	im, _, err := DecodeImage(fi)
+	return im, err
+}
+
+func writePNG(path string, im image.Image) error {
+	fi, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(fi, im); err != nil {
+		fi.Close()
+		return err
+	}
+	return fi.Close()
+}
@@ -0,0 +1,258 @@
+package mosaic
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+func TestSavePalette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "palette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tiles := tileSet(40, 6)
+	tiles = append(tiles, &UniformTile{Uniform: image.NewUniform(color.RGBA{0, 255, 0, 255})})
+	p := NewTilePalette(tiles, 6)
+	if err := SavePalette(dir, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsSavedPalette(dir) {
+		t.Fatalf(errmsg, true, false)
+	}
+
+	loaded, err := LoadPalette(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := loaded.Tiles()
+	if len(got) != len(tiles) {
+		t.Fatalf(errmsg, len(tiles), len(got))
+	}
+
+	for i, tile := range tiles {
+		if palette.NewColorKey(tile) != palette.NewColorKey(got[i]) {
+			t.Errorf(errmsg, palette.NewColorKey(tile), palette.NewColorKey(got[i]))
+		}
+		if size := got[i].Bounds().Size(); size.X != 6 || size.Y != 6 {
+			t.Errorf(errmsg, "6x6", size)
+		}
+	}
+
+	if name := got[3].(palette.Named).Name(); name != tiles[3].(palette.Named).Name() {
+		t.Errorf(errmsg, tiles[3].(palette.Named).Name(), name)
+	}
+
+	// pixels survive the round trip
+	if a, b := tiles[5].At(2, 3), got[5].At(got[5].Bounds().Min.X+2, got[5].Bounds().Min.Y+3); color.RGBAModel.Convert(a) != color.RGBAModel.Convert(b) {
+		t.Errorf(errmsg, a, b)
+	}
+
+	// rescaled on load for other tile sizes
+	rescaled, err := NewSavedTilePalette(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tile := rescaled.Convert(palette.NewColorKey(color.RGBA{0, 255, 0, 255})); tile.Bounds().Dx() != 3 {
+		t.Errorf(errmsg, 3, tile.Bounds().Dx())
+	}
+}
+
+func TestLoadPalette_Version(t *testing.T) {
+	dir, err := ioutil.TempDir("", "palette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	enc, _ := json.Marshal(atlasIndex{Version: PaletteVersion + 1})
+	if err := ioutil.WriteFile(filepath.Join(dir, PaletteManifest), enc, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadPalette(dir); err != (UnsupportedVersion{Version: PaletteVersion + 1}) {
+		t.Errorf(errmsg, UnsupportedVersion{Version: PaletteVersion + 1}, err)
+	}
+}
+
+func TestAtlas_Sheets(t *testing.T) {
+	a := NewAtlas(2)
+	a.Columns = 2
+	for _, tile := range tileSet(5, 2) {
+		if err := a.Add(tile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 4 tiles per sheet
+	if len(a.Sheets) != 2 {
+		t.Fatalf(errmsg, 2, len(a.Sheets))
+	}
+
+	p := NewAtlasPalette(a)
+	tile := a.Tile(4)
+	if tile.Bounds() != image.Rect(0, 0, 2, 2) {
+		t.Errorf(errmsg, image.Rect(0, 0, 2, 2), tile.Bounds())
+	}
+	if got := p.Convert(palette.NewColorKey(tile)); got.(palette.Named).Name() != tile.Name() {
+		t.Errorf(errmsg, tile.Name(), got.(palette.Named).Name())
+	}
+}
+
+// primaries are tiles of flat web safe colors, so they match exactly.
+func primaries(size int) []palette.Tile {
+	var tiles []palette.Tile
+	for _, c := range []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 255, 255}} {
+		tile := NewImageTile(fill(c, size))
+		tiles = append(tiles, tile)
+	}
+	return tiles
+}
+
+func TestAtlas_Decode(t *testing.T) {
+	a := NewAtlas(4)
+	a.Columns = 2
+	for _, tile := range primaries(4) {
+		if err := a.Add(tile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewAtlasPalette(a)
+	// green is the second tile of the sheet, away from its origin, and
+	// differs from the source beneath it
+	d := NewConverter(fill(color.RGBA{0, 240, 0, 255}, 16), "", WithWidth(2), WithHeight(2), WithSize(4),
+		WithPaletteGenerator(palette.GeneratorFunc(func(string, int) (palette.Palette, error) {
+			return p, nil
+		})))
+	out, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pt := range []image.Point{{0, 0}, {5, 2}, {7, 7}} {
+		if c := color.RGBAModel.Convert(out.At(pt.X, pt.Y)); c != (color.RGBA{0, 255, 0, 255}) {
+			t.Errorf(errmsg, color.RGBA{0, 255, 0, 255}, c)
+		}
+	}
+}
+
+func TestLoadAtlas_Columns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "palette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a single row sheet of two 4px tiles
+	if err := writePNG(filepath.Join(dir, "sheet-0.png"), fill(color.White, 8).SubImage(image.Rect(0, 0, 8, 4))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		columns, index int
+	}{
+		{0, 0},
+		{-2, 0},
+		// far more columns than the sheet holds, which would otherwise
+		// allocate gigabytes
+		{1 << 20, 0},
+		// columns overflowing once multiplied by the tile size
+		{1 << 62, 0},
+		// a cell beyond the rows of the sheet
+		{2, 2},
+	} {
+		enc, _ := json.Marshal(atlasIndex{
+			Version: PaletteVersion,
+			Size:    4,
+			Columns: test.columns,
+			Sheets:  []string{"sheet-0.png"},
+			Tiles:   []AtlasEntry{{Sheet: 0, Index: test.index}},
+		})
+		if err := ioutil.WriteFile(filepath.Join(dir, PaletteManifest), enc, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := LoadAtlas(dir); err == nil {
+			t.Errorf("columns %d, index %d: "+errmsg, test.columns, test.index, "error", nil)
+		}
+	}
+}
+
+func TestLoadAtlas_Add(t *testing.T) {
+	dir, err := ioutil.TempDir("", "palette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := NewAtlas(4)
+	a.Columns = 2
+	tiles := primaries(4)
+	if err := a.Add(tiles[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// the trimmed sheet grows again as tiles are added
+	loaded, err := LoadAtlas(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tile := range tiles[1:] {
+		if err := loaded.Add(tile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(loaded.Sheets) != 1 {
+		t.Fatalf(errmsg, 1, len(loaded.Sheets))
+	}
+	for i, tile := range tiles {
+		got := loaded.Tile(i)
+		min := got.Bounds().Min
+		if c, expected := color.RGBAModel.Convert(got.At(min.X, min.Y)), color.RGBAModel.Convert(tile); c != expected {
+			t.Errorf("tile %d: "+errmsg, i, expected, c)
+		}
+	}
+}
+
+func TestSavePalette_Decode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "palette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := SavePalette(dir, NewTilePalette(primaries(4), 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{4, 2} {
+		d := NewConverter(fill(color.RGBA{0, 0, 240, 255}, 16), dir, WithWidth(2), WithHeight(2), WithSize(size),
+			WithPaletteGenerator(palette.GeneratorFunc(NewSavedTilePalette)))
+		out, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// blue is the third saved tile, unlike the source beneath it
+		n := 2 * size
+		for _, pt := range []image.Point{{0, 0}, {n - 1, 0}, {n - 1, n - 1}} {
+			if c := color.RGBAModel.Convert(out.At(pt.X, pt.Y)); c != (color.RGBA{0, 0, 255, 255}) {
+				t.Errorf("size %d: "+errmsg, size, color.RGBA{0, 0, 255, 255}, c)
+			}
+		}
+	}
+}
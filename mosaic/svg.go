@@ -0,0 +1,206 @@
+package mosaic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SVGShape is the shape each cell is drawn as by EncodeSVG.
+type SVGShape int
+
+const (
+	SVGRect SVGShape = iota
+	// SVGCircle draws a circle inscribed in each cell, as with beads or
+	// round tiles.
+	SVGCircle
+)
+
+// SVGTiles selects how EncodeSVG includes image tiles. Flat color
+// tiles are always drawn as shapes.
+type SVGTiles int
+
+const (
+	// SVGTilesNone draws every cell in the average color of its tile.
+	SVGTilesNone SVGTiles = iota
+	// SVGTilesLink references remote tiles by their name, see
+	// palette.Named, and tiles on disk relative to the directory given
+	// by WithLinkDir, falling back to their color.
+	SVGTilesLink
+	// SVGTilesEmbed inlines each distinct tile once as a PNG.
+	SVGTilesEmbed
+)
+
+// EncodeSVG writes the mosaic as an SVG document with one element per
+// cell, at the same dimensions Decode would render. When the converter
+// has an alpha below 255 the source image is embedded beneath the cells
+// as it is when rasterising, as it is too for cells a mask leaves
+// original.
+func (d *Converter) EncodeSVG(w io.Writer, shape SVGShape, tiles SVGTiles) error {
+	srcs, err := d.sources()
+	if err != nil {
+		return err
+	}
+
+	nx, ny := d.width*d.size, d.height*d.size
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", nx, ny, nx, ny)
+
+	var originals bool
+	for _, s := range srcs {
+		originals = originals || s.Image == nil
+	}
+
+	opacity := ""
+	if d.alpha < 255 || originals {
+		uri, err := dataURI(d.im)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, `<image x="0" y="0" width="%d" height="%d" preserveAspectRatio="none" xlink:href="%s"/>`+"\n", nx, ny, uri)
+		if d.alpha < 255 {
+			opacity = fmt.Sprintf(` opacity="%.3f"`, float64(d.alpha)/255)
+		}
+	}
+
+	// embedded tiles are defined once and referenced by each cell
+	symbols := map[image.Image]string{}
+	fmt.Fprintf(bw, "<defs>\n")
+	if shape == SVGCircle {
+		fmt.Fprintf(bw, `<clipPath id="circle" clipPathUnits="objectBoundingBox"><circle cx="0.5" cy="0.5" r="0.5"/></clipPath>`+"\n")
+	}
+	if tiles == SVGTilesEmbed {
+		for _, s := range srcs {
+			if _, ok := symbols[s.Image]; ok || s.Image == nil || !isImageTile(s.Image) {
+				continue
+			}
+			uri, err := dataURI(s.Image)
+			if err != nil {
+				return err
+			}
+			id := fmt.Sprintf("t%d", len(symbols))
+			b := s.Image.Bounds()
+			fmt.Fprintf(bw, `<symbol id="%s" viewBox="0 0 %d %d" preserveAspectRatio="xMidYMid slice"><image width="%d" height="%d" xlink:href="%s"/></symbol>`+"\n",
+				id, b.Dx(), b.Dy(), b.Dx(), b.Dy(), uri)
+			symbols[s.Image] = id
+		}
+	}
+	fmt.Fprintf(bw, "</defs>\n")
+
+	clip := ""
+	if shape == SVGCircle {
+		clip = ` clip-path="url(#circle)"`
+	}
+
+	fmt.Fprintf(bw, "<g%s>\n", opacity)
+	for _, s := range srcs {
+		if s.Image == nil {
+			continue
+		}
+		r := s.Rect
+		if id, ok := symbols[s.Image]; ok {
+			fmt.Fprintf(bw, `<use x="%d" y="%d" width="%d" height="%d" xlink:href="#%s"%s/>`+"\n",
+				r.Min.X, r.Min.Y, r.Dx(), r.Dy(), id, clip)
+			continue
+		}
+		if href := link(s, d.linkDir); tiles == SVGTilesLink && href != "" {
+			fmt.Fprintf(bw, `<image x="%d" y="%d" width="%d" height="%d" preserveAspectRatio="xMidYMid slice" xlink:href="%s"%s/>`+"\n",
+				r.Min.X, r.Min.Y, r.Dx(), r.Dy(), escape(href), clip)
+			continue
+		}
+
+		fill := s.Placement.Color
+		if fill == "" {
+			fill = s.Placement.Target
+		}
+		switch shape {
+		case SVGCircle:
+			fmt.Fprintf(bw, `<circle cx="%s" cy="%s" r="%s" fill="%s"/>`+"\n",
+				num(float64(r.Min.X+r.Max.X)/2), num(float64(r.Min.Y+r.Max.Y)/2), num(radius(r)), fill)
+		default:
+			fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", r.Min.X, r.Min.Y, r.Dx(), r.Dy(), fill)
+		}
+	}
+	fmt.Fprintf(bw, "</g>\n</svg>\n")
+	return bw.Flush()
+}
+
+// isImageTile reports whether im is a tile image rather than a flat
+// color.
+func isImageTile(im image.Image) bool {
+	switch im.(type) {
+	case *image.Uniform, *UniformTile:
+		return false
+	}
+	return true
+}
+
+// link returns where the image tile of s can be found remotely or on
+// disk relative to dir, or "" if it can't be linked to. Tiles on disk
+// are never linked by their absolute path, which would break once the
+// document is moved and reveal the layout of the local filesystem.
+func link(s source, dir string) string {
+	if !isImageTile(s.Image) {
+		return ""
+	}
+	name := s.Placement.Tile
+	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		return name
+	}
+	if dir == "" {
+		return ""
+	}
+	if info, err := os.Stat(name); err != nil || info.IsDir() {
+		return ""
+	}
+
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return ""
+	}
+	return (&url.URL{Path: filepath.ToSlash(rel)}).String()
+}
+
+// dataURI encodes im as a base64 PNG data URI.
+func dataURI(im image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, im); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func radius(r image.Rectangle) float64 {
+	if r.Dx() < r.Dy() {
+		return float64(r.Dx()) / 2
+	}
+	return float64(r.Dy()) / 2
+}
+
+func num(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
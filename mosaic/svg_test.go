@@ -0,0 +1,151 @@
+package mosaic
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// elements parses an SVG document, counting elements by name.
+func elements(t *testing.T, doc []byte) map[string]int {
+	counts := map[string]int{}
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			return counts
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			counts[start.Name.Local]++
+		}
+	}
+}
+
+func TestEncodeSVG(t *testing.T) {
+	im := gradient(40, 40)
+
+	buf := &bytes.Buffer{}
+	d := NewConverter(im, "", WithWidth(4), WithHeight(5), WithSize(10))
+	if err := d.EncodeSVG(buf, SVGRect, SVGTilesNone); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := elements(t, buf.Bytes())
+	if counts["rect"] != 20 || counts["image"] != 0 {
+		t.Errorf(errmsg, "20 rects, 0 images", counts)
+	}
+	if !strings.Contains(buf.String(), `width="40" height="50"`) {
+		t.Errorf(errmsg, `width="40" height="50"`, buf.String()[:200])
+	}
+
+	// the first cell is drawn in the color of its tile
+	first := strings.Index(buf.String(), "<rect")
+	if expected := `<rect x="0" y="0" width="10" height="10" fill="#000000"/>`; !strings.HasPrefix(buf.String()[first:], expected) {
+		t.Errorf(errmsg, expected, buf.String()[first:first+len(expected)])
+	}
+
+	buf.Reset()
+	d = NewConverter(im, "", WithWidth(4), WithHeight(4), WithSize(10), WithAlpha(128))
+	if err := d.EncodeSVG(buf, SVGCircle, SVGTilesNone); err != nil {
+		t.Fatal(err)
+	}
+	counts = elements(t, buf.Bytes())
+	// 16 cells plus the clip path
+	if counts["circle"] != 17 || counts["image"] != 1 {
+		t.Errorf(errmsg, "17 circles, 1 image", counts)
+	}
+	if !strings.Contains(buf.String(), `<g opacity="0.502">`) {
+		t.Errorf(errmsg, `<g opacity="0.502">`, buf.String())
+	}
+}
+
+func TestEncodeSVGTiles(t *testing.T) {
+	tiles := tileSet(4, 6)
+	gen := palette.GeneratorFunc(func(_ string, size int) (palette.Palette, error) {
+		return NewTilePalette(tiles, size), nil
+	})
+	im := fill(color.RGBA{0, 64, 255, 255}, 40)
+
+	buf := &bytes.Buffer{}
+	d := NewConverter(im, "", WithWidth(4), WithHeight(4), WithSize(6), WithPaletteGenerator(gen))
+	if err := d.EncodeSVG(buf, SVGRect, SVGTilesEmbed); err != nil {
+		t.Fatal(err)
+	}
+
+	// every cell shares one embedded tile
+	counts := elements(t, buf.Bytes())
+	if counts["symbol"] != 1 || counts["image"] != 1 || counts["use"] != 16 {
+		t.Errorf(errmsg, "1 symbol, 1 image, 16 uses", counts)
+	}
+
+	// the tiles don't exist on disk, so can't be linked to
+	buf.Reset()
+	if err := d.EncodeSVG(buf, SVGRect, SVGTilesLink); err != nil {
+		t.Fatal(err)
+	}
+	if counts := elements(t, buf.Bytes()); counts["rect"] != 16 {
+		t.Errorf(errmsg, 16, counts["rect"])
+	}
+}
+
+func TestEncodeSVGLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a single tile on disk, with a space in its name
+	tiles := tileSet(1, 6)
+	path := filepath.Join(dir, "tiles", "a b.png")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePNG(path, tiles[0].(*ImageTile).Image); err != nil {
+		t.Fatal(err)
+	}
+	tiles[0].(*ImageTile).Source = path
+
+	for _, test := range []struct {
+		dir  string
+		href string
+	}{
+		// without a directory to be relative to nothing local is linked
+		{"", ""},
+		{filepath.Join(dir, "out"), "../tiles/a%20b.png"},
+		{dir, "tiles/a%20b.png"},
+	} {
+		buf := &bytes.Buffer{}
+		d := NewConverter(fill(color.RGBA{0, 64, 255, 255}, 20), "", WithWidth(2), WithHeight(2), WithSize(6),
+			WithPaletteGenerator(tilePalette(tiles)), WithLinkDir(test.dir))
+		if err := d.EncodeSVG(buf, SVGRect, SVGTilesLink); err != nil {
+			t.Fatal(err)
+		}
+
+		doc := buf.String()
+		if strings.Contains(doc, dir) {
+			t.Errorf("Expected no local paths in the document, Got %s\n", doc)
+		}
+		counts := elements(t, buf.Bytes())
+		if test.href == "" {
+			if counts["rect"] != 4 || counts["image"] != 0 {
+				t.Errorf(errmsg, "4 rects, 0 images", counts)
+			}
+			continue
+		}
+		if n := strings.Count(doc, `xlink:href="`+test.href+`"`); n != 4 {
+			t.Errorf(errmsg, 4, n)
+		}
+	}
+}
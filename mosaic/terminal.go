@@ -0,0 +1,113 @@
+package mosaic
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// Grid is the color of the tile matched to each cell of a mosaic,
+// indexed by row and then column.
+type Grid [][]color.Color
+
+// Grid matches every cell of the source image to a tile exactly as
+// Decode does, but returns only the color of each tile rather than
+// rendering them, which is far cheaper for previews. Cells subdivided by
+// a mask take the mean color of their tiles.
+func (d *Converter) Grid() (Grid, error) {
+	srcs, err := d.sources()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		grid Grid
+		// sums of the sub-cells of subdivided cells, by cell index
+		sums = map[int]*[4]uint64{}
+		n    = map[int]uint64{}
+	)
+	for _, s := range srcs {
+		row, col := s.Cell.Row, s.Cell.Col
+		for len(grid) <= row {
+			grid = append(grid, nil)
+		}
+		for len(grid[row]) <= col {
+			grid[row] = append(grid[row], color.Transparent)
+		}
+
+		c := imageColor(s.Image)
+		if s.Image == nil {
+			// original cells keep the color sampled from the source
+			rgba, err := ParseHexColor(s.Placement.Color)
+			if err != nil {
+				return nil, err
+			}
+			c = rgba
+		} else if c == nil {
+			c = s.Image.At(s.Image.Bounds().Min.X, s.Image.Bounds().Min.Y)
+		}
+		grid[row][col] = c
+
+		if subdivisions(s.Region) < 2 {
+			continue
+		}
+		sum, ok := sums[s.Cell.Index]
+		if !ok {
+			sum = &[4]uint64{}
+			sums[s.Cell.Index] = sum
+		}
+		r, g, b, a := c.RGBA()
+		for i, v := range []uint32{r, g, b, a} {
+			sum[i] += uint64(v)
+		}
+		n[s.Cell.Index]++
+		k := n[s.Cell.Index]
+		grid[row][col] = color.RGBA64{uint16(sum[0] / k), uint16(sum[1] / k), uint16(sum[2] / k), uint16(sum[3] / k)}
+	}
+	return grid, nil
+}
+
+// ASCIIRamp are the characters used by WriteASCII, from darkest to
+// lightest as they appear on a dark terminal background.
+var ASCIIRamp = " .:-=+*#%@"
+
+// WriteANSI writes g using 24-bit ANSI color escapes. Each character is
+// an upper half block colored with one cell in the foreground and the
+// cell below it in the background, so two rows fit on every line and
+// cells come out roughly square.
+func (g Grid) WriteANSI(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for row := 0; row < len(g); row += 2 {
+		for col, top := range g[row] {
+			tr, tg, tb, _ := top.RGBA()
+			fmt.Fprintf(bw, "\x1b[38;2;%d;%d;%dm", tr>>8, tg>>8, tb>>8)
+			if row+1 < len(g) && col < len(g[row+1]) {
+				br, bg, bb, _ := g[row+1][col].RGBA()
+				fmt.Fprintf(bw, "\x1b[48;2;%d;%d;%dm", br>>8, bg>>8, bb>>8)
+			} else {
+				bw.WriteString("\x1b[49m")
+			}
+			bw.WriteString("▀")
+		}
+		bw.WriteString("\x1b[0m\n")
+	}
+	return bw.Flush()
+}
+
+// WriteASCII writes g as plain text, choosing a character from
+// ASCIIRamp by the luminance of each cell. Every cell is two characters
+// wide to make up for the height of terminal characters.
+func (g Grid) WriteASCII(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, row := range g {
+		for _, c := range row {
+			y := color.GrayModel.Convert(c).(color.Gray).Y
+			ch := ASCIIRamp[int(y)*len(ASCIIRamp)/256]
+			bw.WriteByte(ch)
+			bw.WriteByte(ch)
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
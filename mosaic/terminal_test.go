@@ -0,0 +1,75 @@
+package mosaic
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestGrid(t *testing.T) {
+	im := fill(color.RGBA{255, 0, 0, 255}, 40)
+	for y := 20; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			im.Set(x, y, color.RGBA{0, 0, 255, 255})
+		}
+	}
+
+	grid, err := NewConverter(im, "", WithWidth(4), WithHeight(4), WithSize(2)).Grid()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(grid) != 4 || len(grid[0]) != 4 {
+		t.Fatalf(errmsg, "4x4", grid)
+	}
+
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	for row, cells := range grid {
+		expected := red
+		if row >= 2 {
+			expected = blue
+		}
+		for _, c := range cells {
+			if color.RGBAModel.Convert(c) != expected {
+				t.Errorf(errmsg, expected, c)
+			}
+		}
+	}
+
+	if _, err := NewConverter(im, "", WithGapPolicy(GapError), WithMaxDistance(-1)).Grid(); err == nil {
+		t.Errorf(errmsg, "error", err)
+	}
+}
+
+func TestGridWriters(t *testing.T) {
+	grid := Grid{
+		{color.White, color.Black},
+		{color.RGBA{255, 0, 0, 255}, color.Black},
+		{color.Black, color.White},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := grid.WriteASCII(buf); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "@@  \n::  \n  @@\n"; buf.String() != expected {
+		t.Errorf(errmsg, expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := grid.WriteANSI(buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf(errmsg, 2, len(lines))
+	}
+	if expected := "\x1b[38;2;255;255;255m\x1b[48;2;255;0;0m▀"; !strings.HasPrefix(lines[0], expected) {
+		t.Errorf(errmsg, expected, lines[0])
+	}
+	// the odd row out has no cell beneath it
+	if expected := "\x1b[38;2;0;0;0m\x1b[49m▀"; !strings.HasPrefix(lines[1], expected) {
+		t.Errorf(errmsg, expected, lines[1])
+	}
+}
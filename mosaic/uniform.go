@@ -0,0 +1,249 @@
+package mosaic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	plt "image/color/palette"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// NamedPalettes are the built in color sets available to
+// NewUniformColorsPalette by name.
+var NamedPalettes = map[string]color.Palette{
+	"websafe": plt.WebSafe,
+	"plan9":   plt.Plan9,
+}
+
+// NewUniformColorsPalette is a palette.Generator of flat color tiles
+// where term is either the name of one of the NamedPalettes or the path
+// to a color file understood by LoadColors.
+func NewUniformColorsPalette(term string, size int) (palette.Palette, error) {
+	if p, ok := NamedPalettes[strings.ToLower(term)]; ok {
+		return NewUniformPalette(p, size), nil
+	}
+
+	p, err := LoadColors(term)
+	if err != nil {
+		return nil, err
+	}
+	return NewUniformPalette(p, size), nil
+}
+
+// LoadColors reads a list of colors from path, choosing the format from
+// its extension: GIMP palettes (.gpl), Adobe swatch exchange files
+// (.ase) or anything else as one hex color per line.
+func LoadColors(path string) (color.Palette, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p color.Palette
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gpl":
+		p, err = ParseGPL(bytes.NewReader(buf))
+	case ".ase":
+		p, err = ParseASE(bytes.NewReader(buf))
+	default:
+		p, err = ParseHex(bytes.NewReader(buf))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p) == 0 {
+		return nil, EmptyPalette{}
+	}
+	return p, nil
+}
+
+// ParseHex reads one color per line in #rrggbb or #rgb form (the # is
+// optional). Blank lines and lines starting with // or ; are ignored.
+func ParseHex(r io.Reader) (color.Palette, error) {
+	var p color.Palette
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		c, err := ParseHexColor(strings.Fields(line)[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		p = append(p, c)
+	}
+	return p, scanner.Err()
+}
+
+// ParseHexColor parses a color in #rrggbb or #rgb form.
+func ParseHexColor(s string) (color.RGBA, error) {
+	h := strings.TrimPrefix(s, "#")
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+
+	v, err := strconv.ParseUint(h, 16, 32)
+	if len(h) != 6 || err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid hex color %q", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
+// ParseGPL reads a GIMP palette.
+func ParseGPL(r io.Reader) (color.Palette, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "GIMP Palette" {
+		return nil, fmt.Errorf("not a GIMP palette")
+	}
+
+	var p color.Palette
+	for n := 2; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || strings.Contains(strings.SplitN(line, " ", 2)[0], ":") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected R G B", n)
+		}
+
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+			rgb[i] = uint8(v)
+		}
+		p = append(p, color.RGBA{rgb[0], rgb[1], rgb[2], 255})
+	}
+	return p, scanner.Err()
+}
+
+// ASE block types
+const (
+	aseColor      = 0x0001
+	aseGroupStart = 0xc001
+	aseGroupEnd   = 0xc002
+)
+
+// ParseASE reads an Adobe swatch exchange file. RGB, CMYK, LAB and
+// Gray swatches are supported; groups are flattened.
+func ParseASE(rd io.Reader) (color.Palette, error) {
+	// lengths within the file are checked against what's left of it
+	// rather than trusted
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+
+	var header struct {
+		Signature [4]byte
+		Major     uint16
+		Minor     uint16
+		Blocks    uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Signature[:]) != "ASEF" {
+		return nil, fmt.Errorf("not an ASE file")
+	}
+
+	var p color.Palette
+	for i := uint32(0); i < header.Blocks; i++ {
+		var block struct {
+			Type   uint16
+			Length uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &block); err != nil {
+			return nil, err
+		}
+
+		if int64(block.Length) > int64(r.Len()) {
+			return nil, fmt.Errorf("ASE block of %d bytes exceeds the %d bytes remaining", block.Length, r.Len())
+		}
+		body := make([]byte, block.Length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, err
+		}
+
+		if block.Type != aseColor {
+			continue
+		}
+
+		c, err := parseASEColor(body)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, c)
+	}
+	return p, nil
+}
+
+func parseASEColor(b []byte) (color.Color, error) {
+	r := bytes.NewReader(b)
+
+	var nameLen uint16
+	if err := binary.Read(r, binary.BigEndian, &nameLen); err != nil {
+		return nil, err
+	}
+	name := make([]uint16, nameLen)
+	if err := binary.Read(r, binary.BigEndian, name); err != nil {
+		return nil, err
+	}
+
+	var model [4]byte
+	if err := binary.Read(r, binary.BigEndian, &model); err != nil {
+		return nil, err
+	}
+
+	values := map[string]int{"RGB ": 3, "CMYK": 4, "LAB ": 3, "Gray": 1}
+	n, ok := values[string(model[:])]
+	if !ok {
+		return nil, fmt.Errorf("swatch %q: unsupported color model %q", string(utf16.Decode(name)), model)
+	}
+
+	v := make([]float32, n)
+	if err := binary.Read(r, binary.BigEndian, v); err != nil {
+		return nil, err
+	}
+
+	switch string(model[:]) {
+	case "RGB ":
+		return color.RGBA{unit(v[0]), unit(v[1]), unit(v[2]), 255}, nil
+	case "CMYK":
+		k := 1 - float64(v[3])
+		return color.RGBA{
+			unit(float32((1 - float64(v[0])) * k)),
+			unit(float32((1 - float64(v[1])) * k)),
+			unit(float32((1 - float64(v[2])) * k)),
+			255,
+		}, nil
+	case "LAB ":
+		// L is stored as a fraction, A and B as is
+		return color.RGBAModel.Convert(palette.Lab{L: float64(v[0]) * 100, A: float64(v[1]), B: float64(v[2])}), nil
+	}
+	g := unit(v[0])
+	return color.RGBA{g, g, g, 255}, nil
+}
+
+// unit converts a component in [0, 1] to 8-bit.
+func unit(v float32) uint8 {
+	return uint8(math.Max(0, math.Min(1, float64(v)))*255 + 0.5)
+}
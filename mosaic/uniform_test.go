@@ -0,0 +1,233 @@
+package mosaic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+func TestParseHex(t *testing.T) {
+	p, err := ParseHex(strings.NewReader("// brand\n#ff0000\n\n0f0 primary green\n; comment\n#0000FF\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := color.Palette{
+		color.RGBA{255, 0, 0, 255},
+		color.RGBA{0, 255, 0, 255},
+		color.RGBA{0, 0, 255, 255},
+	}
+	if len(p) != len(expected) {
+		t.Fatalf(errmsg, len(expected), len(p))
+	}
+	for i, c := range expected {
+		if p[i] != c {
+			t.Errorf(errmsg, c, p[i])
+		}
+	}
+
+	if _, err := ParseHex(strings.NewReader("#ff0000\n#ggg\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf(errmsg, "error on line 2", err)
+	}
+}
+
+func TestParseGPL(t *testing.T) {
+	gpl := "GIMP Palette\nName: Test\nColumns: 2\n#\n255 0 0\tRed\n  0 128 255\tAzure\n"
+	p, err := ParseGPL(strings.NewReader(gpl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p) != 2 {
+		t.Fatalf(errmsg, 2, len(p))
+	}
+	if c := (color.RGBA{0, 128, 255, 255}); p[1] != c {
+		t.Errorf(errmsg, c, p[1])
+	}
+
+	if _, err := ParseGPL(strings.NewReader("255 0 0\n")); err == nil {
+		t.Errorf(errmsg, "error", err)
+	}
+}
+
+type swatch struct {
+	model  string
+	values []float32
+}
+
+// ase builds an ASE file with a group containing the given swatches.
+func ase(swatches ...swatch) []byte {
+	block := func(buf *bytes.Buffer, typ uint16, body []byte) {
+		binary.Write(buf, binary.BigEndian, typ)
+		binary.Write(buf, binary.BigEndian, uint32(len(body)))
+		buf.Write(body)
+	}
+	name := func(buf *bytes.Buffer, s string) {
+		n := append(utf16.Encode([]rune(s)), 0)
+		binary.Write(buf, binary.BigEndian, uint16(len(n)))
+		binary.Write(buf, binary.BigEndian, n)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("ASEF")
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0})
+	binary.Write(&buf, binary.BigEndian, uint32(len(swatches)+2))
+
+	var group bytes.Buffer
+	name(&group, "group")
+	block(&buf, aseGroupStart, group.Bytes())
+
+	for _, s := range swatches {
+		var body bytes.Buffer
+		name(&body, s.model)
+		body.WriteString(s.model)
+		binary.Write(&body, binary.BigEndian, s.values)
+		binary.Write(&body, binary.BigEndian, uint16(2))
+		block(&buf, aseColor, body.Bytes())
+	}
+
+	block(&buf, aseGroupEnd, nil)
+	return buf.Bytes()
+}
+
+func TestParseASE(t *testing.T) {
+	b := ase(
+		swatch{"RGB ", []float32{1, 0.5, 0}},
+		swatch{"CMYK", []float32{0, 1, 1, 0}},
+		swatch{"Gray", []float32{0.5}},
+		swatch{"LAB ", []float32{1, 0, 0}},
+	)
+
+	p, err := ParseASE(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := color.Palette{
+		color.RGBA{255, 128, 0, 255},
+		color.RGBA{255, 0, 0, 255},
+		color.RGBA{128, 128, 128, 255},
+		color.RGBA{255, 255, 255, 255},
+	}
+	if len(p) != len(expected) {
+		t.Fatalf(errmsg, len(expected), len(p))
+	}
+	for i, c := range expected {
+		if d := palette.Distance(c, p[i]); d > 2 {
+			t.Errorf(errmsg, c, p[i])
+		}
+	}
+
+	if _, err := ParseASE(strings.NewReader("GIMP")); err == nil {
+		t.Errorf(errmsg, "error", err)
+	}
+
+	// a block claiming more than the file holds
+	truncated := append([]byte(nil), b[:12]...)
+	truncated = append(truncated, 0x00, 0x01, 0xff, 0xff, 0xff, 0xff, 0, 0)
+	if _, err := ParseASE(bytes.NewReader(truncated)); err == nil {
+		t.Errorf(errmsg, "error", err)
+	}
+}
+
+func TestConverter_DecodeMeanColor(t *testing.T) {
+	// 125 bins to web safe 102, nearer 100 than 140, though it is
+	// nearer 140 itself
+	grays := palette.GeneratorFunc(func(_ string, size int) (palette.Palette, error) {
+		return NewUniformPalette(color.Palette{color.RGBA{100, 100, 100, 255}, color.RGBA{140, 140, 140, 255}}, size), nil
+	})
+	im := fill(color.RGBA{125, 125, 125, 255}, 8)
+
+	for mean, expected := range map[bool]string{false: "#646464", true: "#8c8c8c"} {
+		d := NewConverter(im, "", WithWidth(1), WithHeight(1), WithSize(4), WithPaletteGenerator(grays), WithMeanColor(mean))
+		if _, err := d.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Manifest()[0].Color; got != expected {
+			t.Errorf("mean %v: "+errmsg, mean, expected, got)
+		}
+	}
+}
+
+func TestNewUniformColorsPalette(t *testing.T) {
+	p, err := NewUniformColorsPalette("Plan9", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(p.(*TilePalette).Tiles()); n != 256 {
+		t.Errorf(errmsg, 256, n)
+	}
+
+	if _, err := NewUniformColorsPalette("missing.gpl", 10); err == nil {
+		t.Errorf(errmsg, "error", err)
+	}
+}
+
+// quads returns an image split into four flat colored quadrants.
+func quads(size int) image.Image {
+	im := image.NewRGBA(image.Rect(0, 0, size, size))
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 0, 255}}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			im.Set(x, y, colors[(y*2/size)*2+x*2/size])
+		}
+	}
+	return im
+}
+
+func TestQuantisers(t *testing.T) {
+	im := quads(32)
+	for name, q := range map[string]Quantiser{"median": MedianCut, "kmeans": KMeans} {
+		p := q(im, 4)
+		if len(p) != 4 {
+			t.Fatalf("%s: "+errmsg, name, 4, len(p))
+		}
+
+		// every quadrant color is recovered exactly
+		for _, c := range []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 0, 255}} {
+			if got := p.Convert(c); got != c {
+				t.Errorf("%s: "+errmsg, name, c, got)
+			}
+		}
+	}
+}
+
+func TestKMeansImprovesMedianCut(t *testing.T) {
+	im := gradient(64, 64)
+	err := func(p color.Palette) (total float64) {
+		b := im.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := im.At(x, y)
+				total += math.Pow(palette.Distance(c, p.Convert(c)), 2)
+			}
+		}
+		return
+	}
+
+	median, kmeans := err(MedianCut(im, 8)), err(KMeans(im, 8))
+	if kmeans > median {
+		t.Errorf(errmsg, median, kmeans)
+	}
+}
+
+func TestNewQuantisedPalette(t *testing.T) {
+	p, err := NewQuantisedPalette(quads(16), 2, MedianCut).Palette("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(p.(*TilePalette).Tiles()); n != 2 {
+		t.Errorf(errmsg, 2, n)
+	}
+
+	if _, err := NewQuantisedPalette(image.NewRGBA(image.Rect(0, 0, 0, 0)), 2, MedianCut).Palette("", 10); err != (EmptyPalette{}) {
+		t.Errorf(errmsg, EmptyPalette{}, err)
+	}
+}
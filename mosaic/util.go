@@ -38,3 +38,7 @@ func Resize(m image.Image, width, height int) (image.Image, error) {
 type ImageNotSuitable struct{}
 
 func (i ImageNotSuitable) Error() string { return "image not suitable for tiling" }
+
+type EmptyPalette struct{}
+
+func (e EmptyPalette) Error() string { return "palette contains no tiles" }
@@ -0,0 +1,43 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	// a wide image is cropped to its centre square
+	im := fill(color.RGBA{255, 0, 0, 255}, 30)
+	wide := image.NewRGBA(image.Rect(0, 0, 50, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 50; x++ {
+			wide.Set(x, y, color.RGBA{0, 0, 255, 255})
+			if x >= 10 && x < 40 {
+				wide.Set(x, y, im.At(0, 0))
+			}
+		}
+	}
+
+	out, err := Resize(wide, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := out.Bounds().Size(); size != image.Pt(10, 10) {
+		t.Errorf(errmsg, image.Pt(10, 10), size)
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	for _, p := range []image.Point{{0, 0}, {9, 9}, {5, 5}} {
+		if c := color.RGBAModel.Convert(out.At(p.X, p.Y)); c != red {
+			t.Errorf(errmsg, red, c)
+		}
+	}
+}
+
+func TestResize_TooSmall(t *testing.T) {
+	if _, err := Resize(fill(color.Black, 5), 10, 10); err != (ImageNotSuitable{}) {
+		t.Errorf(errmsg, ImageNotSuitable{}, err)
+	}
+}
@@ -0,0 +1,237 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/GeorgeMac/gomosaic/mosaic"
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+// Generator is a palette.Generator which sources its tiles from a
+// remote image search endpoint. The endpoint is queried with the search
+// term and is expected to respond with a JSON list of image URLs.
+type Generator struct {
+	endpoint string
+	param    string
+	cache    string
+	client   *http.Client
+	interval time.Duration
+	retries  int
+	backoff  time.Duration
+	limit    int
+
+	// mu guards last, so generators shared between goroutines still
+	// respect the rate limit
+	mu   sync.Mutex
+	last time.Time
+}
+
+func NewGenerator(endpoint string, opts ...option) *Generator {
+	g := &Generator{
+		endpoint: endpoint,
+		param:    "q",
+		client:   &http.Client{Timeout: 30 * time.Second},
+		interval: 100 * time.Millisecond,
+		retries:  3,
+		backoff:  500 * time.Millisecond,
+		limit:    100,
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// Palette searches the endpoint for term and builds a TilePalette from
+// the returned images, each scaled to size/size px. Images which cannot
+// be fetched or decoded are skipped.
+func (g *Generator) Palette(term string, size int) (palette.Palette, error) {
+	urls, err := g.search(term)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(urls) > g.limit {
+		urls = urls[:g.limit]
+	}
+
+	tiles := make([]palette.Tile, 0, len(urls))
+	for _, u := range urls {
+		im, err := g.tile(u, size)
+		if err != nil {
+			log.Printf("[remote] Skipping %s: %s\n", u, err)
+			continue
+		}
+		tile := mosaic.NewImageTile(im)
+		tile.Source = u
+		tiles = append(tiles, tile)
+	}
+
+	if len(tiles) == 0 {
+		return nil, NoTilesFound{Term: term}
+	}
+
+	return mosaic.NewTilePalette(tiles, size), nil
+}
+
+func (g *Generator) search(term string) ([]string, error) {
+	u, err := url.Parse(g.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set(g.param, term)
+	u.RawQuery = q.Encode()
+
+	body, err := g.get(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	if err := json.Unmarshal(body, &urls); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// tile returns the image at u scaled to size/size px, reading it from
+// the cache directory when it has been fetched before.
+func (g *Generator) tile(u string, size int) (image.Image, error) {
+	path := g.cachePath(u, size)
+	if path != "" {
+		if fi, err := os.Open(path); err == nil {
+			defer fi.Close()
+			im, _, err := mosaic.DecodeImage(fi)
+			return im, err
+		}
+	}
+
+	body, err := g.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	im, _, err := mosaic.DecodeImage(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	im, err = mosaic.Resize(im, size, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if path != "" {
+		if err := writePNG(path, im); err != nil {
+			log.Printf("[remote] Error caching %s: %s\n", u, err)
+		}
+	}
+	return im, nil
+}
+
+func (g *Generator) cachePath(u string, size int) string {
+	if g.cache == "" {
+		return ""
+	}
+	sum := sha1.Sum([]byte(u))
+	return filepath.Join(g.cache, fmt.Sprintf("%s_%d.png", hex.EncodeToString(sum[:]), size))
+}
+
+// get performs a rate limited GET request against u, retrying with an
+// exponential backoff on transport errors and 5xx responses.
+func (g *Generator) get(u string) ([]byte, error) {
+	var (
+		err     error
+		backoff = g.backoff
+	)
+
+	for attempt := 0; attempt <= g.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		g.wait()
+
+		var body []byte
+		body, err = g.fetch(u)
+		if err == nil {
+			return body, nil
+		}
+
+		if e, ok := err.(StatusError); ok && e.Code < 500 {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (g *Generator) fetch(u string) ([]byte, error) {
+	resp, err := g.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, StatusError{URL: u, Code: resp.StatusCode}
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// wait blocks until at least interval has passed since the previous
+// request was made. Concurrent callers wait their turn.
+func (g *Generator) wait() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if d := g.interval - time.Since(g.last); d > 0 {
+		time.Sleep(d)
+	}
+	g.last = time.Now()
+}
+
+func writePNG(path string, im image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	fi, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	return png.Encode(fi, im)
+}
+
+// errors
+
+type NoTilesFound struct {
+	Term string
+}
+
+func (n NoTilesFound) Error() string { return fmt.Sprintf("no tiles found for term %q", n.Term) }
+
+type StatusError struct {
+	URL  string
+	Code int
+}
+
+func (s StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d fetching %s", s.Code, s.URL)
+}
@@ -0,0 +1,150 @@
+package remote
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GeorgeMac/gomosaic/mosaic/palette"
+)
+
+var errmsg string = "Expected %v, Got %v\n"
+
+func stub(fails int) (*httptest.Server, *int) {
+	var (
+		mux  = http.NewServeMux()
+		hits = 0
+		srv  = httptest.NewServer(mux)
+	)
+
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "cats" {
+			json.NewEncoder(w).Encode([]string{})
+			return
+		}
+		json.NewEncoder(w).Encode([]string{
+			srv.URL + "/red.png",
+			srv.URL + "/blue.png",
+			srv.URL + "/missing.png",
+		})
+	})
+
+	serve := func(c color.Color) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			if hits <= fails {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			im := image.NewRGBA(image.Rect(0, 0, 20, 20))
+			draw.Draw(im, im.Bounds(), image.NewUniform(c), image.ZP, draw.Src)
+			png.Encode(w, im)
+		}
+	}
+	mux.HandleFunc("/red.png", serve(color.RGBA{255, 0, 0, 255}))
+	mux.HandleFunc("/blue.png", serve(color.RGBA{0, 0, 255, 255}))
+
+	return srv, &hits
+}
+
+func TestGenerator_Palette(t *testing.T) {
+	srv, hits := stub(1)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g := NewGenerator(srv.URL+"/search",
+		WithCacheDir(dir),
+		WithRateLimit(1000),
+		WithRetries(2, time.Millisecond))
+
+	p, err := g.Palette("cats", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	if tile := p.Convert(palette.NewColorKey(red)); tile == nil || tile.Bounds().Dx() != 10 {
+		t.Errorf(errmsg, "10px tile", tile)
+	}
+
+	// first red request fails and is retried
+	if *hits != 3 {
+		t.Errorf(errmsg, 3, *hits)
+	}
+
+	cached, _ := filepath.Glob(filepath.Join(dir, "*.png"))
+	if len(cached) != 2 {
+		t.Errorf(errmsg, 2, len(cached))
+	}
+
+	// second run is served from the cache
+	if _, err := g.Palette("cats", 10); err != nil {
+		t.Fatal(err)
+	}
+	if *hits != 3 {
+		t.Errorf(errmsg, 3, *hits)
+	}
+}
+
+func TestGenerator_PaletteNoTiles(t *testing.T) {
+	srv, _ := stub(0)
+	defer srv.Close()
+
+	g := NewGenerator(srv.URL+"/search", WithRateLimit(1000))
+	if _, err := g.Palette("dogs", 10); err != (NoTilesFound{Term: "dogs"}) {
+		t.Errorf(errmsg, NoTilesFound{Term: "dogs"}, err)
+	}
+}
+
+func TestGenerator_RateLimitConcurrent(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		json.NewEncoder(w).Encode([]string{})
+	}))
+	defer srv.Close()
+
+	// one generator shared by several callers, as with mask palettes
+	g := NewGenerator(srv.URL, WithRateLimit(20))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Palette("cats", 10)
+		}()
+	}
+	wg.Wait()
+
+	if len(times) != 4 {
+		t.Fatalf(errmsg, 4, len(times))
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	for i := 1; i < len(times); i++ {
+		// allow for the server noting times a little after requests
+		if gap := times[i].Sub(times[i-1]); gap < 40*time.Millisecond {
+			t.Errorf(errmsg, ">= 50ms between requests", gap)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package remote
+
+import (
+	"net/http"
+	"time"
+)
+
+type option func(g *Generator)
+
+// WithCacheDir stores fetched tiles in dir, which can later be used
+// as a local tile directory.
+func WithCacheDir(dir string) option {
+	return func(g *Generator) {
+		g.cache = dir
+	}
+}
+
+// WithQueryParam sets the name of the query parameter the search term
+// is passed to the endpoint as (defaults to "q").
+func WithQueryParam(p string) option {
+	return func(g *Generator) {
+		g.param = p
+	}
+}
+
+// WithRateLimit sets the maximum number of requests made per second.
+func WithRateLimit(n int) option {
+	return func(g *Generator) {
+		if n > 0 {
+			g.interval = time.Second / time.Duration(n)
+		}
+	}
+}
+
+func WithRetries(n int, backoff time.Duration) option {
+	return func(g *Generator) {
+		g.retries = n
+		g.backoff = backoff
+	}
+}
+
+// WithLimit sets the maximum number of images fetched per term.
+func WithLimit(n int) option {
+	return func(g *Generator) {
+		g.limit = n
+	}
+}
+
+func WithClient(c *http.Client) option {
+	return func(g *Generator) {
+		g.client = c
+	}
+}